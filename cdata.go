@@ -0,0 +1,85 @@
+package xml2json
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	cdataStart = "<![CDATA["
+	cdataEnd   = "]]>"
+)
+
+// rawRecorder keeps the bytes read by the XML decoder since the end of the previous token.
+// encoding/xml reports CDATA sections as ordinary character data, the raw bytes of a token tell them apart.
+type rawRecorder struct {
+	reader *bufio.Reader
+	buf    []byte
+	// base is the offset of the first byte of buf
+	base int64
+	// disabled is set once the decoder reads through a charset converter,
+	// offsets do not match the raw bytes anymore
+	disabled bool
+}
+
+func newRawRecorder(reader io.Reader) *rawRecorder {
+	return &rawRecorder{reader: bufio.NewReader(reader)}
+}
+
+// ReadByte makes encoding/xml read from the recorder without buffering of its own
+func (r *rawRecorder) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil && !r.disabled {
+		r.buf = append(r.buf, b)
+	}
+	return b, err
+}
+
+func (r *rawRecorder) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if !r.disabled {
+		r.buf = append(r.buf, p[:n]...)
+	}
+	return n, err
+}
+
+// charsetReader disables the recorder before converting the document to UTF-8
+func (r *rawRecorder) charsetReader(label string, input io.Reader) (io.Reader, error) {
+	r.disabled = true
+	r.buf = nil
+	return charset.NewReaderLabel(label, input)
+}
+
+// isCDATA returns whether the bytes between the given offsets are a CDATA section
+func (r *rawRecorder) isCDATA(start int64, end int64) bool {
+	from, to := start-r.base, end-r.base
+	if r.disabled || from < 0 || from > to || to > int64(len(r.buf)) {
+		return false
+	}
+
+	raw := r.buf[from:to]
+	return bytes.HasPrefix(raw, []byte(cdataStart)) && bytes.HasSuffix(raw, []byte(cdataEnd))
+}
+
+// forget drops the bytes before the given offset
+func (r *rawRecorder) forget(offset int64) {
+	n := offset - r.base
+	if n <= 0 {
+		return
+	}
+	if n > int64(len(r.buf)) {
+		n = int64(len(r.buf))
+	}
+
+	r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+	r.base += n
+}
+
+// escapeCDATA splits the CDATA end marker found in s across two sections
+func escapeCDATA(s string) string {
+	return strings.ReplaceAll(s, cdataEnd, "]]"+cdataEnd+cdataStart+">")
+}
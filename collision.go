@@ -0,0 +1,153 @@
+package xml2json
+
+import (
+	"fmt"
+)
+
+// collisionPrefix is prepended to attribute names by CollisionPrefixAttribute
+const collisionPrefix = "@"
+
+// CollisionPolicy tells what to do when an attribute and a child element of an element share the same key,
+// e.g. <a id="1"><id>2</id></a> without attribute prefix
+type CollisionPolicy int
+
+const (
+	// CollisionMerge keeps both in a single array, the attribute first (default)
+	CollisionMerge CollisionPolicy = iota
+	// CollisionFail makes decoding fail with a *CollisionError
+	CollisionFail
+	// CollisionPrefixAttribute prepends "@" to the key of the attribute
+	CollisionPrefixAttribute
+	// CollisionKeepElement drops the attribute
+	CollisionKeepElement
+	// CollisionKeepAttribute drops the element
+	CollisionKeepAttribute
+)
+
+func (p CollisionPolicy) String() string {
+	switch p {
+	case CollisionMerge:
+		return "merge"
+	case CollisionFail:
+		return "fail"
+	case CollisionPrefixAttribute:
+		return "prefix attribute"
+	case CollisionKeepElement:
+		return "keep element"
+	case CollisionKeepAttribute:
+		return "keep attribute"
+	default:
+		return fmt.Sprintf("CollisionPolicy(%d)", int(p))
+	}
+}
+
+// Collision describes an attribute and a child element sharing the same key
+type Collision struct {
+	// Path is the dotted path of the element, see Node.Label
+	Path string
+	Key  string
+	// Policy is the policy which was applied
+	Policy CollisionPolicy
+}
+
+// CollisionError is returned when decoding with CollisionFail
+type CollisionError struct {
+	Collision
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("attribute and element share key %q in %s", e.Key, e.Path)
+}
+
+// resolveCollisions applies the collision policy to the children of an element
+func (dec *Decoder) resolveCollisions(elem *element) error {
+	n := elem.n
+	// Merged collisions need nothing to be done unless reported
+	if len(n.Children) == 0 || (dec.collisionPolicy == CollisionMerge && dec.onCollision == nil) {
+		return nil
+	}
+
+	for _, key := range n.ChildKeys() {
+		children := n.Children[key]
+		// Attributes are added before child elements
+		if children[0].Type != AttributeNode || children[len(children)-1].Type != ElementNode {
+			continue
+		}
+
+		collision := Collision{Path: elem.path, Key: key, Policy: dec.collisionPolicy}
+		if dec.onCollision != nil {
+			dec.onCollision(collision)
+		}
+
+		var attrs, elements Nodes
+		for _, c := range children {
+			if c.Type == AttributeNode {
+				attrs = append(attrs, c)
+			} else {
+				elements = append(elements, c)
+			}
+		}
+
+		switch dec.collisionPolicy {
+		case CollisionFail:
+			return &CollisionError{Collision: collision}
+		case CollisionPrefixAttribute:
+			n.Children[key] = elements
+			n.insertChildren(key, collisionPrefix+key, attrs)
+		case CollisionKeepElement:
+			n.Children[key] = elements
+		case CollisionKeepAttribute:
+			n.Children[key] = attrs
+			n.Segments = removeSegments(n.Segments, elements)
+		}
+	}
+
+	return nil
+}
+
+// insertChildren adds children under a new key placed right before another one
+func (n *Node) insertChildren(before string, key string, children Nodes) {
+	if _, exists := n.Children[key]; exists {
+		n.Children[key] = append(n.Children[key], children...)
+		return
+	}
+
+	n.Children[key] = children
+	n.dropKey(key)
+	if !n.listed[before] {
+		// Keys missing from the list come last, see ChildKeys
+		return
+	}
+
+	keys := make([]string, 0, len(n.keys)+1)
+	for _, k := range n.keys {
+		if k == before {
+			keys = append(keys, key)
+		}
+		keys = append(keys, k)
+	}
+	n.keys = keys
+	n.listed[key] = true
+}
+
+// removeSegments drops the segments holding one of the given nodes
+func removeSegments(segments []Segment, nodes Nodes) []Segment {
+	if len(segments) == 0 {
+		return segments
+	}
+
+	result := segments[:0]
+	for _, s := range segments {
+		removed := false
+		for _, n := range nodes {
+			if s.Node == n {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,23 @@
+package xml2json
+
+import (
+	"context"
+	"io"
+)
+
+// contextCheckInterval is the number of tokens read, or nodes written, between two context checks
+const contextCheckInterval = 256
+
+// contextReader stops reading once its context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	err := r.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
@@ -0,0 +1,61 @@
+package xml2json
+
+import (
+	"sort"
+)
+
+// Convention is a well-known way of converting XML to JSON, see WithConvention
+type Convention int
+
+const (
+	// BadgerFish writes attributes under "@" prefixed keys and text under "$",
+	// elements are always objects and in-scope namespaces are listed under "@xmlns",
+	// the default namespace being under "$". See http://badgerfish.ning.com
+	BadgerFish Convention = iota + 1
+	// Parker drops attributes and the document element, elements holding text only become
+	// bare values, empty elements become null and the text of elements holding children is dropped.
+	// Values are typed as with WithTypeConverter(Bool, Int, Float)
+	Parker
+	// JsonML writes elements as arrays, ["tag", {"attr": "value"}, child...], children being
+	// text and elements in document order. Attributes are written under their name without prefix.
+	// Text is kept as is, see WhitespacePreserve. Comments, with WithComments, are written as ["!", "text"]
+	// and processing instructions, with WithProcInsts, as ["?target", "instruction"].
+	// See http://www.jsonml.org
+	JsonML
+)
+
+const (
+	badgerFishAttrPrefix = "@"
+	badgerFishText       = "$"
+)
+
+// addNamespaces adds the namespaces in scope of the element as an "@xmlns" attribute
+func (dec *Decoder) addNamespaces(elem *element) {
+	scope := make(map[string]string)
+	for e := elem; e != nil; e = e.parent {
+		for prefix, uri := range e.ns {
+			if _, shadowed := scope[prefix]; !shadowed {
+				scope[prefix] = uri
+			}
+		}
+	}
+	if len(scope) == 0 {
+		return
+	}
+
+	prefixes := make([]string, 0, len(scope))
+	for prefix := range scope {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	xmlns := &Node{Type: AttributeNode}
+	for _, prefix := range prefixes {
+		key := prefix
+		if prefix == "" {
+			key = badgerFishText
+		}
+		xmlns.AddChild(key, &Node{Data: scope[prefix], Type: AttributeNode})
+	}
+	elem.n.AddChild(dec.attributePrefix+xmlnsPrefix, xmlns)
+}
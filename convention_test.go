@@ -0,0 +1,195 @@
+package xml2json_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	xml2json "github.com/txix-open/goxml2json"
+)
+
+func TestConvention_Suite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &TestConvention{})
+}
+
+type TestConvention struct {
+	suite.Suite
+}
+
+func (t *TestConvention) SetupSuite() {}
+
+type conventionScenario struct {
+	in       string
+	expected string
+}
+
+func (t *TestConvention) assertScenarios(converter xml2json.Converter, table []conventionScenario) {
+	for _, scenario := range table {
+		actual, err := converter.Convert(strings.NewReader(scenario.in))
+		t.NoError(err, scenario.in)
+		t.JSONEq(scenario.expected, actual.String(), scenario.in)
+	}
+}
+
+// TestBadgerFish checks the examples of http://badgerfish.ning.com
+func (t *TestConvention) TestBadgerFish() {
+	table := []conventionScenario{
+		{
+			in:       `<alice>bob</alice>`,
+			expected: `{ "alice": { "$" : "bob" } }`,
+		},
+		{
+			in:       `<alice><bob>charlie</bob><david>edgar</david></alice>`,
+			expected: `{ "alice": { "bob" : { "$": "charlie" }, "david": { "$": "edgar"} } }`,
+		},
+		{
+			in:       `<alice><bob>charlie</bob><bob>david</bob></alice>`,
+			expected: `{ "alice": { "bob" : [{"$": "charlie" }, {"$": "david" }] } }`,
+		},
+		{
+			in:       `<alice charlie="david">bob</alice>`,
+			expected: `{ "alice": { "$" : "bob", "@charlie" : "david" } }`,
+		},
+		{
+			in:       `<alice xmlns="http://some-namespace">bob</alice>`,
+			expected: `{ "alice": { "$" : "bob", "@xmlns": { "$" : "http:\/\/some-namespace" } } }`,
+		},
+		{
+			in:       `<alice xmlns="http://some-namespace" xmlns:charlie="http://some-other-namespace">bob</alice>`,
+			expected: `{ "alice": { "$" : "bob", "@xmlns": { "$" : "http:\/\/some-namespace", "charlie" : "http:\/\/some-other-namespace" } } }`,
+		},
+		{
+			in: `<alice xmlns="http://some-namespace" xmlns:charlie="http://some-other-namespace">
+				<bob>david</bob>
+				<charlie:edgar>frank</charlie:edgar>
+			</alice>`,
+			expected: `{ "alice" : {
+				"bob" : { "$" : "david" , "@xmlns" : {"charlie" : "http:\/\/some-other-namespace" , "$" : "http:\/\/some-namespace"} },
+				"charlie:edgar" : { "$" : "frank" , "@xmlns" : {"charlie":"http:\/\/some-other-namespace", "$" : "http:\/\/some-namespace"} },
+				"@xmlns" : { "charlie" : "http:\/\/some-other-namespace", "$" : "http:\/\/some-namespace"}
+			} }`,
+		},
+		{
+			in:       `<alice><bob/></alice>`,
+			expected: `{ "alice": { "bob": {} } }`,
+		},
+	}
+
+	t.assertScenarios(xml2json.NewConverter(xml2json.WithConvention(xml2json.BadgerFish)), table)
+}
+
+func (t *TestConvention) TestParker() {
+	table := []conventionScenario{
+		{
+			in:       `<root>test</root>`,
+			expected: `"test"`,
+		},
+		{
+			in:       `<root/>`,
+			expected: `null`,
+		},
+		{
+			in:       `<root><name>Xml</name><encoding>ASCII</encoding></root>`,
+			expected: `{"name": "Xml", "encoding": "ASCII"}`,
+		},
+		{
+			in:       `<root><item>1</item><item>2</item><item>three</item></root>`,
+			expected: `{"item": [1, 2, "three"]}`,
+		},
+		{
+			in:       `<root><item/><flag>true</flag></root>`,
+			expected: `{"item": null, "flag": true}`,
+		},
+		{
+			in:       `<root>testing<b>123</b></root>`,
+			expected: `{"b": 123}`,
+		},
+		{
+			in:       `<?xml version="1.0"?><root version="2"><data><name lang="en">n</name></data></root>`,
+			expected: `{"data": {"name": "n"}}`,
+		},
+	}
+
+	t.assertScenarios(xml2json.NewConverter(xml2json.WithConvention(xml2json.Parker)), table)
+
+	// Records are not stripped
+	buf := new(bytes.Buffer)
+	err := xml2json.NewRecordConverter("root.item", xml2json.WithConvention(xml2json.Parker)).
+		Convert(strings.NewReader(`<root><item id="1"><a>1</a></item><item>x</item></root>`), buf)
+	t.NoError(err)
+	t.Equal("{\"a\": 1}\n\"x\"\n", buf.String())
+}
+
+// TestJsonML checks the examples of http://www.jsonml.org
+func (t *TestConvention) TestJsonML() {
+	table := []conventionScenario{
+		{
+			in:       `<li>Plain text</li>`,
+			expected: `["li", "Plain text"]`,
+		},
+		{
+			in:       `<br/>`,
+			expected: `["br"]`,
+		},
+		{
+			in:       `<li style="color:red">First Item</li>`,
+			expected: `["li", {"style": "color:red"}, "First Item"]`,
+		},
+		{
+			in:       `<p>Some <b>bold</b> text</p>`,
+			expected: `["p", "Some ", ["b", "bold"], " text"]`,
+		},
+		{
+			in: `<ul>
+				<li style="color:red">First Item</li>
+				<li title="Some hover text." style="color:green">Second Item</li>
+				<li><span class="code-example-third">Third</span> Item</li>
+			</ul>`,
+			expected: `["ul",
+				["li", {"style": "color:red"}, "First Item"],
+				["li", {"title": "Some hover text.", "style": "color:green"}, "Second Item"],
+				["li", ["span", {"class": "code-example-third"}, "Third"], " Item"]
+			]`,
+		},
+		{
+			in:       `<?xml version="1.0"?><!-- note --><a><b>1</b><c/><b>2</b></a>`,
+			expected: `["a", ["b", "1"], ["c"], ["b", "2"]]`,
+		},
+	}
+
+	t.assertScenarios(xml2json.NewConverter(xml2json.WithConvention(xml2json.JsonML)), table)
+
+	// Comments and processing instructions are kept in place when captured
+	t.assertScenarios(xml2json.NewConverter(
+		xml2json.WithConvention(xml2json.JsonML),
+		xml2json.WithComments("#comment"),
+		xml2json.WithProcInsts("?"),
+	), []conventionScenario{
+		{
+			in:       `<a>x<!--c-->y<?pi data?><?empty?></a>`,
+			expected: `["a", "x", ["!", "c"], "y", ["?pi", "data"], ["?empty"]]`,
+		},
+	})
+
+	// Records are named after the last segment of their path
+	buf := new(bytes.Buffer)
+	err := xml2json.NewRecordConverter("root.item", xml2json.WithConvention(xml2json.JsonML)).
+		Convert(strings.NewReader(`<root><item id="1"><a>1</a></item><item>x</item></root>`), buf)
+	t.NoError(err)
+	t.Equal("[\"item\", {\"id\": \"1\"}, [\"a\", \"1\"]]\n[\"item\", \"x\"]\n", buf.String())
+
+	// Trees built by hand have their text first
+	root := &xml2json.Node{}
+	item := &xml2json.Node{Data: "text"}
+	item.AddChild("b", &xml2json.Node{Data: "bold"})
+	root.AddChild("item", item)
+	buf.Reset()
+	err = xml2json.NewEncoder(buf, xml2json.WithConvention(xml2json.JsonML)).Encode(root)
+	t.NoError(err)
+	t.JSONEq(`["item", "text", ["b", "bold"]]`, buf.String())
+
+	err = xml2json.NewEncoder(buf, xml2json.WithConvention(xml2json.JsonML)).Encode(&xml2json.Node{})
+	t.Error(err)
+}
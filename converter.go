@@ -1,7 +1,9 @@
 package xml2json
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"io"
 
 	"github.com/pkg/errors"
@@ -19,17 +21,95 @@ func NewConverter(plugins ...Plugin) Converter {
 
 // Convert converts the given XML document to JSON
 func (s Converter) Convert(r io.Reader) (*bytes.Buffer, error) {
+	return s.ConvertContext(context.Background(), r)
+}
+
+// ConvertContext is like Convert but stops as soon as the context is done
+func (s Converter) ConvertContext(ctx context.Context, r io.Reader) (*bytes.Buffer, error) {
 	root := &Node{}
-	err := NewDecoder(r, s.plugins...).Decode(root)
+	err := NewDecoder(r, s.plugins...).DecodeContext(ctx, root)
 	if err != nil {
 		return nil, errors.WithMessage(err, "decode xml")
 	}
 
 	buf := new(bytes.Buffer)
-	err = NewEncoder(buf, s.plugins...).Encode(root)
+	err = NewEncoder(buf, s.plugins...).EncodeContext(ctx, root)
 	if err != nil {
 		return nil, errors.WithMessage(err, "encode json")
 	}
 
 	return buf, nil
 }
+
+// RecordConverter converts every element found at a dotted path to a JSON document of its own
+type RecordConverter struct {
+	path    string
+	plugins []Plugin
+}
+
+// NewRecordConverter returns a converter for the elements found at path, e.g. "osm.node"
+func NewRecordConverter(path string, plugins ...Plugin) RecordConverter {
+	return RecordConverter{
+		path:    path,
+		plugins: plugins,
+	}
+}
+
+// Convert streams the records of the given XML document to w as newline delimited JSON.
+// Each record is dropped once written, so documents larger than memory can be converted
+func (s RecordConverter) Convert(r io.Reader, w io.Writer) error {
+	return s.ConvertContext(context.Background(), r, w)
+}
+
+// ConvertContext is like Convert but stops as soon as the context is done
+func (s RecordConverter) ConvertContext(ctx context.Context, r io.Reader, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := NewEncoder(bw, s.plugins...)
+	err := NewDecoder(r, s.plugins...).DecodeRecordsContext(ctx, s.path, func(record *Node) error {
+		err := enc.EncodeContext(ctx, record)
+		if err != nil {
+			return errors.WithMessage(err, "encode json")
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithMessage(err, "decode xml")
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		return errors.WithMessage(err, "flush json")
+	}
+
+	return nil
+}
+
+// ReverseConverter converts JSON documents produced by a Converter back to XML
+type ReverseConverter struct {
+	plugins []Plugin
+}
+
+// NewReverseConverter returns a converter understanding the conventions of the given plugins,
+// it should be given the plugins the JSON document was converted with
+func NewReverseConverter(plugins ...Plugin) ReverseConverter {
+	return ReverseConverter{
+		plugins: plugins,
+	}
+}
+
+// Convert converts the given JSON document to XML
+func (s ReverseConverter) Convert(r io.Reader) (*bytes.Buffer, error) {
+	root := &Node{}
+	err := NewJSONDecoder(r, s.plugins...).Decode(root)
+	if err != nil {
+		return nil, errors.WithMessage(err, "decode json")
+	}
+
+	buf := new(bytes.Buffer)
+	err = NewXMLEncoder(buf, s.plugins...).Encode(root)
+	if err != nil {
+		return nil, errors.WithMessage(err, "encode xml")
+	}
+
+	return buf, nil
+}
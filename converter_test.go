@@ -1,6 +1,10 @@
 package xml2json_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
 	"strings"
 	"testing"
 
@@ -200,3 +204,833 @@ func (t *TestConverter) TestConvertISO() {
 	t.NoError(err)
 	t.JSONEq(string(expected), actual.String())
 }
+
+func (t *TestConverter) TestConvertPreservesDocumentOrder() {
+	s := `<doc b="1" a="2"><z>1</z><y>2</y><z>3</z><x/></doc>`
+
+	expected := `{"doc": [{"-b": ["1"], "-a": ["2"], "z": ["1", "3"], "y": ["2"], "x": [""]}]}` + "\n"
+
+	for i := 0; i < 10; i++ {
+		actual, err := t.converter.Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.Equal(expected, actual.String())
+	}
+}
+
+func (t *TestConverter) TestConvertMixedContent() {
+	s := `<doc><p class="lead">Hello <b>big</b> world<i>!</i></p><title>plain</title></doc>`
+
+	table := []struct {
+		mode     xml2json.MixedContentMode
+		expected string
+	}{
+		{
+			mode:     xml2json.MixedContentNone,
+			expected: `{"doc": {"p": {"-class": "lead", "b": "big", "i": "!", "#content": "world"}, "title": "plain"}}`,
+		},
+		{
+			mode:     xml2json.MixedContentConcat,
+			expected: `{"doc": {"p": {"-class": "lead", "b": "big", "i": "!", "#content": "Hello  world"}, "title": "plain"}}`,
+		},
+		{
+			mode: xml2json.MixedContentParts,
+			expected: `{"doc": {"p": {
+				"-class": "lead",
+				"#content": ["Hello", {"b": "big"}, "world", {"i": "!"}]
+			}, "title": "plain"}}`,
+		},
+	}
+
+	for _, scenario := range table {
+		converter := xml2json.NewConverter(
+			xml2json.WithAttrPrefix("-"),
+			xml2json.WithContentPrefix("#"),
+			xml2json.WithMixedContent(scenario.mode),
+		)
+		actual, err := converter.Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.JSONEq(scenario.expected, actual.String())
+	}
+}
+
+func (t *TestConverter) TestConvertMixedContentConcat() {
+	table := []struct {
+		in         string
+		whitespace xml2json.WhitespaceMode
+		expected   string
+	}{
+		{in: `<p>foo<b>x</b>bar</p>`, expected: `{"p": {"b": "x", "#content": "foobar"}}`},
+		{in: `<p> foo <b>x</b> bar </p>`, expected: `{"p": {"b": "x", "#content": "foo  bar"}}`},
+		{
+			in:         `<p> foo <b>x</b> bar </p>`,
+			whitespace: xml2json.WhitespaceCollapse,
+			expected:   `{"p": {"b": "x", "#content": "foo bar"}}`,
+		},
+		{in: "<p>\n  <![CDATA[ x ]]>\n  <b/>\n</p>", expected: `{"p": {"b": "", "#content": " x "}}`},
+		{in: `<p> a <![CDATA[ x ]]> b </p>`, expected: `{"p": "a  x  b"}`},
+	}
+
+	for _, scenario := range table {
+		converter := xml2json.NewConverter(
+			xml2json.WithContentPrefix("#"),
+			xml2json.WithMixedContent(xml2json.MixedContentConcat),
+			xml2json.WithWhitespace(scenario.whitespace),
+			xml2json.WithCDATA(""),
+		)
+		actual, err := converter.Convert(strings.NewReader(scenario.in))
+		t.NoError(err)
+		t.JSONEq(scenario.expected, actual.String(), scenario.in)
+	}
+}
+
+func (t *TestConverter) TestConvertNamespaces() {
+	s := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:o="urn:other">
+		<soap:Body o:id="1">a</soap:Body>
+		<o:Body xml:lang="en">b</o:Body>
+		<Body xmlns="urn:default">c</Body>
+	</soap:Envelope>`
+
+	table := []struct {
+		plugins  []xml2json.Plugin
+		expected string
+	}{
+		{
+			plugins:  []xml2json.Plugin{xml2json.WithNamespaces(xml2json.NamespaceStrip)},
+			expected: `{"Envelope": {"-soap": "http://schemas.xmlsoap.org/soap/envelope/", "-o": "urn:other", "Body": [{"-id": "1", "#content": "a"}, {"-lang": "en", "#content": "b"}, {"-xmlns": "urn:default", "#content": "c"}]}}`,
+		},
+		{
+			plugins:  []xml2json.Plugin{xml2json.WithNamespaces(xml2json.NamespacePrefix)},
+			expected: `{"soap:Envelope": {"soap:Body": {"-o:id": "1", "#content": "a"}, "o:Body": {"-xml:lang": "en", "#content": "b"}, "Body": "c"}}`,
+		},
+		{
+			plugins: []xml2json.Plugin{
+				xml2json.WithNamespacePrefixes(map[string]string{"http://schemas.xmlsoap.org/soap/envelope/": "env"}),
+				xml2json.WithNamespaceDeclarations(),
+			},
+			expected: `{"env:Envelope": {"-xmlns:env": "http://schemas.xmlsoap.org/soap/envelope/", "-xmlns:o": "urn:other", "env:Body": {"-o:id": "1", "#content": "a"}, "o:Body": {"-xml:lang": "en", "#content": "b"}, "Body": {"-xmlns": "urn:default", "#content": "c"}}}`,
+		},
+		{
+			plugins:  []xml2json.Plugin{xml2json.WithNamespaces(xml2json.NamespaceClark)},
+			expected: `{"{http://schemas.xmlsoap.org/soap/envelope/}Envelope": {"{http://schemas.xmlsoap.org/soap/envelope/}Body": {"-{urn:other}id": "1", "#content": "a"}, "{urn:other}Body": {"-{http://www.w3.org/XML/1998/namespace}lang": "en", "#content": "b"}, "{urn:default}Body": "c"}}`,
+		},
+	}
+
+	for _, scenario := range table {
+		plugins := append([]xml2json.Plugin{
+			xml2json.WithAttrPrefix("-"),
+			xml2json.WithContentPrefix("#"),
+		}, scenario.plugins...)
+		actual, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.JSONEq(scenario.expected, actual.String())
+	}
+}
+
+func (t *TestConverter) TestConvertClarkPaths() {
+	s := `<o:order xmlns:o="http://example.com/ns/order" o:id="1">
+		<o:line><o:qty>2</o:qty></o:line>
+		<o:note>internal</o:note>
+	</o:order>`
+
+	plugins := []xml2json.Plugin{
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithNamespaces(xml2json.NamespaceClark),
+		xml2json.ExcludePaths("**.{http://example.com/ns/order}note"),
+		xml2json.AttrToArray("{http://example.com/ns/order}order.{http://example.com/ns/order}line"),
+		xml2json.WithTypeHints(map[string]xml2json.JSType{"**.{http://example.com/ns/order}qty": xml2json.Int}),
+		xml2json.WithRenames(map[string]string{"{http://example.com/ns/order}order.-{http://example.com/ns/order}id": "id"}),
+	}
+	actual, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.JSONEq(`{"{http://example.com/ns/order}order": {
+		"id": "1",
+		"{http://example.com/ns/order}line": [{"{http://example.com/ns/order}qty": 2}]
+	}}`, actual.String())
+
+	buf := new(bytes.Buffer)
+	err = xml2json.NewRecordConverter("{http://example.com/ns/order}order.{http://example.com/ns/order}line", plugins...).
+		Convert(strings.NewReader(s), buf)
+	t.NoError(err)
+	t.JSONEq(`{"{http://example.com/ns/order}qty": 2}`, buf.String())
+
+	root := &xml2json.Node{}
+	err = xml2json.NewDecoder(strings.NewReader(s), plugins...).Decode(root)
+	t.NoError(err)
+	t.Equal("2", root.GetChild("{http://example.com/ns/order}order.{http://example.com/ns/order}line.{http://example.com/ns/order}qty").Data)
+	nodes, err := root.Query("*.{http://example.com/ns/order}line[{http://example.com/ns/order}qty=2]")
+	t.NoError(err)
+	t.Len(nodes, 1)
+}
+
+func (t *TestConverter) TestRecordConverter() {
+	s := `<?xml version="1.0" encoding="UTF-8"?>
+  <osm version="0.6">
+   <bounds minlat="54.0889580"/>
+   <node id="1"/>
+   <way><node id="ignored"/></way>
+   <node id="2"><tag k="name" v="Neu Broderstorf"/></node>
+  </osm>`
+
+	converter := xml2json.NewRecordConverter(
+		"osm.node",
+		xml2json.WithAttrPrefix("-"),
+		xml2json.AttrToArray("osm.node.tag"),
+	)
+	buf := new(bytes.Buffer)
+	err := converter.Convert(strings.NewReader(s), buf)
+	t.NoError(err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	t.Require().Len(lines, 2)
+	t.JSONEq(`{"-id": "1"}`, lines[0])
+	t.JSONEq(`{"-id": "2", "tag": [{"-k": "name", "-v": "Neu Broderstorf"}]}`, lines[1])
+}
+
+func (t *TestConverter) TestRecordConverterTruncated() {
+	converter := xml2json.NewRecordConverter("osm.node")
+	err := converter.Convert(strings.NewReader(`<osm><node>1</node><node>2`), new(bytes.Buffer))
+	t.Error(err)
+}
+
+func (t *TestConverter) TestRecordConverterHandlerError() {
+	s := `<osm><node><name>a</name></node><node><item_id>1</item_id><itemId>2</itemId></node></osm>`
+
+	converter := xml2json.NewRecordConverter("osm.node", xml2json.WithKeyTransform(xml2json.CamelCase))
+	err := converter.Convert(strings.NewReader(s), new(bytes.Buffer))
+	t.Require().Error(err)
+	t.Contains(err.Error(), "handle record: encode json")
+
+	var decodeErr *xml2json.DecodeError
+	t.Require().ErrorAs(err, &decodeErr)
+	t.Equal("osm.node", decodeErr.Path)
+
+	var collisionErr *xml2json.KeyCollisionError
+	t.Require().ErrorAs(err, &collisionErr)
+	t.Equal("itemId", collisionErr.Key)
+}
+
+func (t *TestConverter) TestReverseConvert() {
+	s := `{"osm": [{
+		"-version": ["0.6"],
+		"bounds": [{"-minlat": ["54.0889580"]}],
+		"node": [
+			{"-id": ["1"], "-visible": [true]},
+			{"-id": ["2"], "tag": [{"-k": ["name"], "-v": ["Neu \"Broderstorf\""]}]}
+		],
+		"foo": ["bar & baz"],
+		"empty": [null],
+		"mixed": [{"-attr": ["attribute"], "#content": ["content"]}],
+		"p": [{"#content": ["Hello", {"b": ["big"]}, "world"]}]
+	}]}`
+
+	expected := `<osm version="0.6">` +
+		`<bounds minlat="54.0889580"/>` +
+		`<node id="1" visible="true"/>` +
+		`<node id="2"><tag k="name" v="Neu &#34;Broderstorf&#34;"/></node>` +
+		`<foo>bar &amp; baz</foo>` +
+		`<empty/>` +
+		`<mixed attr="attribute">content</mixed>` +
+		`<p>Hello<b>big</b>world</p>` +
+		`</osm>` + "\n"
+
+	converter := xml2json.NewReverseConverter(
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithContentPrefix("#"),
+	)
+	actual, err := converter.Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.Equal(expected, actual.String())
+}
+
+func (t *TestConverter) TestReverseConvertRoundTrip() {
+	s := `<osm version="0.6"><node id="1"><tag k="name" v="a"/><tag k="ref" v="b"/></node><foo>bar</foo></osm>` + "\n"
+
+	json, err := t.converter.Convert(strings.NewReader(s))
+	t.NoError(err)
+
+	actual, err := xml2json.NewReverseConverter(
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithContentPrefix("#"),
+		xml2json.AllAttrToArray(),
+	).Convert(json)
+	t.NoError(err)
+	t.Equal(s, actual.String())
+}
+
+func (t *TestConverter) TestReverseConvertInvalid() {
+	converter := xml2json.NewReverseConverter(xml2json.WithAttrPrefix("-"))
+
+	for _, s := range []string{`[]`, `{"a": [[1]]}`, `{"a": {"-b": {}}}`, `{"a": {"-b": [1, 2]}}`, `{"a": `} {
+		_, err := converter.Convert(strings.NewReader(s))
+		t.Error(err, s)
+	}
+}
+
+func (t *TestConverter) TestReverseConvertHostileNames() {
+	converter := xml2json.NewReverseConverter(
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithComments("#comment"),
+		xml2json.WithProcInsts("?"),
+	)
+
+	for _, s := range []string{
+		`{"a b=\"1\"><evil/><x": "1"}`,
+		`{"a": {"-b=\"1\" c": "1"}}`,
+		`{"a": {"-b><evil/": "1"}}`,
+		`{"a><evil/><b": {"c": "1"}}`,
+		`{"1a": "1"}`,
+		`{"a:b:c": "1"}`,
+		`{":a": "1"}`,
+		`{"a": {"b c": "1"}}`,
+		`{"{urn:x}a b": "1"}`,
+		`{"a": {"#comment": "--><evil/><!--"}}`,
+		`{"a": {"?pi": "?><evil/><?pi"}}`,
+		`{"a": {"?pi x": ""}}`,
+		`{"a": {"?xml": "version=\"1.0\""}}`,
+	} {
+		actual, err := converter.Convert(strings.NewReader(s))
+		t.Error(err, s)
+		if actual != nil {
+			t.NotContains(actual.String(), "<evil/>", s)
+		}
+	}
+
+	actual, err := converter.Convert(strings.NewReader(`{"ns:a": {"-xmlns:ns": "urn:x", "-_b.c-d": "1", "\u00e9l\u00e8ve": "x"}}`))
+	t.NoError(err)
+	t.Equal("<ns:a xmlns:ns=\"urn:x\" _b.c-d=\"1\"><\u00e9l\u00e8ve>x</\u00e9l\u00e8ve></ns:a>\n", actual.String())
+}
+
+func (t *TestConverter) TestReverseConvertCollisions() {
+	// The merged key holds the attribute first, then the elements
+	s := `<a id="1"><id>2</id><id>3</id></a>`
+	root := &xml2json.Node{}
+	t.Require().NoError(xml2json.NewDecoder(strings.NewReader(s)).Decode(root))
+
+	buf := new(bytes.Buffer)
+	t.NoError(xml2json.NewXMLEncoder(buf).Encode(root))
+	t.Equal(s+"\n", buf.String())
+
+	a := root.GetChild("a")
+	a.AddChild("id", &xml2json.Node{Data: "4", Type: xml2json.AttributeNode})
+	t.Error(xml2json.NewXMLEncoder(new(bytes.Buffer)).Encode(root))
+}
+
+func (t *TestConverter) TestConvertContext() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := t.converter.ConvertContext(ctx, strings.NewReader(`<a>b</a>`))
+	t.ErrorIs(err, context.Canceled)
+
+	// Cancel while the document is being read
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	r := io.MultiReader(
+		strings.NewReader(`<osm>`+strings.Repeat(`<node id="1"/>`, 1000)),
+		readerFunc(func(p []byte) (int, error) {
+			cancel()
+			return copy(p, `<node id="2"/>`), nil
+		}),
+		strings.NewReader(strings.Repeat(`<node id="3"/>`, 1000)+`</osm>`),
+	)
+	_, err = t.converter.ConvertContext(ctx, r)
+	t.ErrorIs(err, context.Canceled)
+	t.Contains(err.Error(), "decoding stopped after")
+
+	// Cancel while the tree is being encoded
+	root := &xml2json.Node{}
+	err = xml2json.NewDecoder(strings.NewReader(`<osm>` + strings.Repeat(`<node id="1"/>`, 1000) + `</osm>`)).Decode(root)
+	t.NoError(err)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = xml2json.NewEncoder(new(bytes.Buffer)).EncodeContext(ctx, root)
+	t.ErrorIs(err, context.Canceled)
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+func (t *TestConverter) TestConvertCDATA() {
+	s := "<doc>\n" +
+		"  <script>\n    <![CDATA[  if (a < b) {\n\treturn \"]]]]><![CDATA[>\"\n}  ]]>\n  </script>\n" +
+		"  <text>  <![CDATA[]]>  </text>\n" +
+		"  <p>Hello <![CDATA[ <b> ]]> world</p>\n" +
+		"  <plain>  trimmed  </plain>\n" +
+		"</doc>"
+
+	plugins := []xml2json.Plugin{
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithContentPrefix("#"),
+		xml2json.WithMixedContent(xml2json.MixedContentParts),
+		xml2json.WithCDATA("#cdata"),
+	}
+
+	actual, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.JSONEq(`{"doc": {
+		"script": {"#cdata": "  if (a < b) {\n\treturn \"]]>\"\n}  "},
+		"text": {"#cdata": ""},
+		"p": {"#content": ["Hello", {"#cdata": " <b> "}, "world"]},
+		"plain": "trimmed"
+	}}`, actual.String())
+
+	expected := "<doc>" +
+		"<script><![CDATA[  if (a < b) {\n\treturn \"]]]]><![CDATA[>\"\n}  ]]></script>" +
+		"<text><![CDATA[]]></text>" +
+		"<p>Hello<![CDATA[ <b> ]]>world</p>" +
+		"<plain>trimmed</plain>" +
+		"</doc>\n"
+	reversed, err := xml2json.NewReverseConverter(plugins...).Convert(actual)
+	t.NoError(err)
+	t.Equal(expected, reversed.String())
+
+	// Without a key CDATA content is kept as is but not marked
+	actual, err = xml2json.NewConverter(xml2json.WithCDATA("")).Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.JSONEq(`{"doc": {
+		"script": "  if (a < b) {\n\treturn \"]]>\"\n}  ",
+		"text": "",
+		"p": "world",
+		"plain": "trimmed"
+	}}`, actual.String())
+}
+
+func (t *TestConverter) TestConvertCommentsAndProcInsts() {
+	s := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<?xml-stylesheet href="style.xsl" type="text/xsl"?>` +
+		`<!-- schema v2 -->` +
+		`<doc><a>1</a><!-- first --><b>2</b><?app refresh?></doc>`
+
+	plugins := []xml2json.Plugin{
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithComments("#comment"),
+		xml2json.WithProcInsts("?"),
+	}
+
+	actual, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.Equal(`{"?xml-stylesheet": "href=\"style.xsl\" type=\"text/xsl\"", "#comment": " schema v2 ", `+
+		`"doc": {"a": "1", "#comment": " first ", "b": "2", "?app": "refresh"}}`+"\n", actual.String())
+
+	reversed, err := xml2json.NewReverseConverter(plugins...).Convert(actual)
+	t.NoError(err)
+	t.Equal(`<?xml-stylesheet href="style.xsl" type="text/xsl"?><!-- schema v2 -->`+
+		`<doc><a>1</a><!-- first --><b>2</b><?app refresh?></doc>`+"\n", reversed.String())
+
+	// Grouped at the position of the first one
+	actual, err = xml2json.NewConverter(plugins...).Convert(strings.NewReader(`<doc><a>1</a><!-- first --><b>2</b><!-- second --></doc>`))
+	t.NoError(err)
+	t.Equal(`{"doc": {"a": "1", "#comment": [" first ", " second "], "b": "2"}}`+"\n", actual.String())
+
+	// In document position when keeping mixed content parts
+	mixed := []xml2json.Plugin{
+		xml2json.WithComments("#comment"),
+		xml2json.WithMixedContent(xml2json.MixedContentParts),
+	}
+	actual, err = xml2json.NewConverter(mixed...).Convert(strings.NewReader(`<p>Hello <!-- name --> world<!-- end --></p>`))
+	t.NoError(err)
+	t.JSONEq(`{"p": {"content": ["Hello", {"#comment": " name "}, "world", {"#comment": " end "}]}}`, actual.String())
+
+	reversed, err = xml2json.NewReverseConverter(mixed...).Convert(actual)
+	t.NoError(err)
+	t.Equal(`<p>Hello<!-- name -->world<!-- end --></p>`+"\n", reversed.String())
+
+	// Dropped by default
+	actual, err = t.converter.Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.JSONEq(`{"doc": [{"a": ["1"], "b": ["2"]}]}`, actual.String())
+}
+
+func (t *TestConverter) TestConvertWhitespace() {
+	s := "<doc>\n" +
+		"  <poem xml:space=\"preserve\">\n    Roses are red,\n    violets are blue\n  </poem>\n" +
+		"  <note xml:space=\"preserve\">\n    <line xml:space=\"default\">  kept  </line>\n  </note>\n" +
+		"  <code>  a  \u2028  b  </code>\n" +
+		"  <title>  A   long\n   title  </title>\n" +
+		"</doc>"
+
+	table := []struct {
+		plugins  []xml2json.Plugin
+		expected string
+	}{
+		{
+			expected: `{"doc": {
+				"poem": {"-space": "preserve", "#content": "\n    Roses are red,\n    violets are blue\n  "},
+				"note": {"-space": "preserve", "line": {"-space": "default", "#content": "kept"}},
+				"code": "a  \u2028  b",
+				"title": "A   long\n   title"
+			}}`,
+		},
+		{
+			plugins: []xml2json.Plugin{
+				xml2json.WithWhitespace(xml2json.WhitespaceCollapse),
+				xml2json.WithWhitespace(xml2json.WhitespaceNormalizeNewlines, "doc.code"),
+				xml2json.WithoutXMLSpace(),
+			},
+			expected: `{"doc": {
+				"poem": {"-space": "preserve", "#content": "Roses are red, violets are blue"},
+				"note": {"-space": "preserve", "line": {"-space": "default", "#content": "kept"}},
+				"code": "  a  \n  b  ",
+				"title": "A long title"
+			}}`,
+		},
+		{
+			plugins: []xml2json.Plugin{
+				xml2json.WithWhitespace(xml2json.WhitespacePreserve, "**.line", "**.title"),
+			},
+			expected: `{"doc": {
+				"poem": {"-space": "preserve", "#content": "\n    Roses are red,\n    violets are blue\n  "},
+				"note": {"-space": "preserve", "line": {"-space": "default", "#content": "  kept  "}},
+				"code": "a  \u2028  b",
+				"title": "  A   long\n   title  "
+			}}`,
+		},
+	}
+
+	for _, scenario := range table {
+		plugins := append([]xml2json.Plugin{
+			xml2json.WithAttrPrefix("-"),
+			xml2json.WithContentPrefix("#"),
+		}, scenario.plugins...)
+		actual, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.JSONEq(scenario.expected, actual.String())
+	}
+}
+
+func (t *TestConverter) TestConvertCollisions() {
+	s := `<doc><a id="1" name="x"><id>2</id><id>3</id></a></doc>`
+
+	table := []struct {
+		policy   xml2json.CollisionPolicy
+		expected string
+	}{
+		{policy: xml2json.CollisionMerge, expected: `{"doc": {"a": {"id": ["1", "2", "3"], "name": "x"}}}`},
+		{policy: xml2json.CollisionPrefixAttribute, expected: `{"doc": {"a": {"@id": "1", "id": ["2", "3"], "name": "x"}}}`},
+		{policy: xml2json.CollisionKeepElement, expected: `{"doc": {"a": {"id": ["2", "3"], "name": "x"}}}`},
+		{policy: xml2json.CollisionKeepAttribute, expected: `{"doc": {"a": {"id": "1", "name": "x"}}}`},
+	}
+
+	for _, scenario := range table {
+		var collisions []xml2json.Collision
+		converter := xml2json.NewConverter(
+			xml2json.WithCollisionPolicy(scenario.policy),
+			xml2json.OnCollision(func(c xml2json.Collision) {
+				collisions = append(collisions, c)
+			}),
+		)
+		actual, err := converter.Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.Equal(scenario.expected+"\n", actual.String())
+		t.Equal([]xml2json.Collision{{Path: "doc.a", Key: "id", Policy: scenario.policy}}, collisions)
+	}
+
+	_, err := xml2json.NewConverter(xml2json.WithCollisionPolicy(xml2json.CollisionFail)).Convert(strings.NewReader(s))
+	var collisionErr *xml2json.CollisionError
+	t.Require().ErrorAs(err, &collisionErr)
+	t.Equal("doc.a", collisionErr.Path)
+	t.Equal("id", collisionErr.Key)
+
+	// No collision with an attribute prefix
+	_, err = xml2json.NewConverter(
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithCollisionPolicy(xml2json.CollisionFail),
+	).Convert(strings.NewReader(s))
+	t.NoError(err)
+}
+
+func (t *TestConverter) TestConvertWithContentKey() {
+	s := `<doc><price cur="EUR">12.50</price><p>Hello <b>big</b> world</p></doc>`
+
+	for _, key := range []string{"#text", "$t", "_", "value"} {
+		plugins := []xml2json.Plugin{
+			xml2json.WithAttrPrefix("-"),
+			xml2json.WithContentPrefix("#"),
+			xml2json.WithContentKey(key),
+			xml2json.WithMixedContent(xml2json.MixedContentParts),
+		}
+
+		actual, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.JSONEq(`{"doc": {
+			"price": {"-cur": "EUR", "`+key+`": "12.50"},
+			"p": {"`+key+`": ["Hello", {"b": "big"}, "world"]}
+		}}`, actual.String())
+
+		reversed, err := xml2json.NewReverseConverter(plugins...).Convert(actual)
+		t.NoError(err)
+		t.Equal(`<doc><price cur="EUR">12.50</price><p>Hello<b>big</b>world</p></doc>`+"\n", reversed.String())
+	}
+}
+
+func (t *TestConverter) TestConvertKeyTransform() {
+	s := `<Order-Line_Item ORDERID="7" xmlns:ns="urn:x"><ns:Unit_Price cur="EUR">12.50</ns:Unit_Price><XMLHttpRequest/></Order-Line_Item>`
+
+	table := []struct {
+		transform xml2json.KeyTransform
+		expected  string
+	}{
+		{
+			transform: xml2json.CamelCase,
+			expected:  `{"orderLineItem": {"-orderid": "7", "ns:unitPrice": {"-cur": "EUR", "#content": "12.50"}, "xmlHttpRequest": ""}}`,
+		},
+		{
+			transform: xml2json.SnakeCase,
+			expected:  `{"order_line_item": {"-orderid": "7", "ns:unit_price": {"-cur": "EUR", "#content": "12.50"}, "xml_http_request": ""}}`,
+		},
+		{
+			transform: xml2json.KebabCase,
+			expected:  `{"order-line-item": {"-orderid": "7", "ns:unit-price": {"-cur": "EUR", "#content": "12.50"}, "xml-http-request": ""}}`,
+		},
+		{
+			transform: xml2json.LowerCase,
+			expected:  `{"order-line_item": {"-orderid": "7", "ns:unit_price": {"-cur": "EUR", "#content": "12.50"}, "xmlhttprequest": ""}}`,
+		},
+		{
+			transform: strings.ToUpper,
+			expected:  `{"ORDER-LINE_ITEM": {"-ORDERID": "7", "ns:UNIT_PRICE": {"-CUR": "EUR", "#content": "12.50"}, "XMLHTTPREQUEST": ""}}`,
+		},
+	}
+
+	for _, scenario := range table {
+		actual, err := xml2json.NewConverter(
+			xml2json.WithAttrPrefix("-"),
+			xml2json.WithContentPrefix("#"),
+			xml2json.WithNamespaces(xml2json.NamespacePrefix),
+			xml2json.WithKeyTransform(scenario.transform),
+		).Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.JSONEq(scenario.expected, actual.String())
+	}
+
+	// Clark notation keeps its URI
+	actual, err := xml2json.NewConverter(
+		xml2json.WithNamespaces(xml2json.NamespaceClark),
+		xml2json.WithKeyTransform(xml2json.SnakeCase),
+	).Convert(strings.NewReader(`<a xmlns="urn:Some_Thing"><ItemName>x</ItemName></a>`))
+	t.NoError(err)
+	t.JSONEq(`{"{urn:Some_Thing}a": {"{urn:Some_Thing}item_name": "x"}}`, actual.String())
+
+	_, err = xml2json.NewConverter(xml2json.WithKeyTransform(xml2json.CamelCase)).
+		Convert(strings.NewReader(`<doc><item_id>1</item_id><itemId>2</itemId></doc>`))
+	var collisionErr *xml2json.KeyCollisionError
+	t.Require().ErrorAs(err, &collisionErr)
+	t.Equal("doc", collisionErr.Path)
+	t.Equal("itemId", collisionErr.Key)
+	t.Equal([]string{"item_id", "itemId"}, collisionErr.Names)
+}
+
+func (t *TestConverter) TestConvertRenames() {
+	s := `<Envelope><Body><GetPriceResponse><Price cur="EUR">12.50</Price><Tax cur="EUR">2</Tax></GetPriceResponse></Body></Envelope>`
+
+	actual, err := xml2json.NewConverter(
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithContentPrefix("#"),
+		xml2json.WithKeyTransform(xml2json.CamelCase),
+		xml2json.WithRenames(map[string]string{
+			"Envelope.Body.GetPriceResponse.Price":      "price",
+			"Envelope.Body.GetPriceResponse.Price.-cur": "currency",
+			"**.Tax.*": "TAX_CUR",
+		}),
+	).Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.JSONEq(`{"envelope": {"body": {"getPriceResponse": {
+		"price": {"currency": "EUR", "#content": "12.50"},
+		"tax": {"TAX_CUR": "EUR", "#content": "2"}
+	}}}}`, actual.String())
+
+	_, err = xml2json.NewConverter(xml2json.WithRenames(map[string]string{"doc.a": "b"})).
+		Convert(strings.NewReader(`<doc><a>1</a><b>2</b></doc>`))
+	var collisionErr *xml2json.KeyCollisionError
+	t.Require().ErrorAs(err, &collisionErr)
+	t.Equal([]string{"a", "b"}, collisionErr.Names)
+}
+
+func (t *TestConverter) TestConvertPathProjection() {
+	s := `<Envelope id="e">
+		<Header><Security><Signature>xyz</Signature></Security></Header>
+		<Body>text<!-- note -->
+			<Order id="1" ref="a"><Line n="1">x</Line><Signature>abc</Signature></Order>
+			<Order id="2" ref="b"><Line n="2">y</Line></Order>
+			<Invoice id="3"/>
+		</Body>
+	</Envelope>`
+
+	table := []struct {
+		plugins  []xml2json.Plugin
+		expected string
+	}{
+		{
+			plugins: []xml2json.Plugin{xml2json.ExcludePaths("Envelope.Header", "**.Signature", "**.-ref")},
+			expected: `{"Envelope": {"-id": "e", "Body": {"#comment": " note ",
+				"Order": [{"-id": "1", "Line": {"-n": "1", "#content": "x"}}, {"-id": "2", "Line": {"-n": "2", "#content": "y"}}],
+				"Invoice": {"-id": "3"}
+			}}}`,
+		},
+		{
+			plugins:  []xml2json.Plugin{xml2json.IncludePaths("Envelope.Body.Order.Line")},
+			expected: `{"Envelope": {"Body": {"Order": [{"Line": {"-n": "1", "#content": "x"}}, {"Line": {"-n": "2", "#content": "y"}}]}}}`,
+		},
+		{
+			plugins:  []xml2json.Plugin{xml2json.IncludePaths("**.-id", "**.Security")},
+			expected: `{"Envelope": {"-id": "e", "Header": {"Security": {"Signature": "xyz"}}, "Body": {"Order": [{"-id": "1"}, {"-id": "2"}], "Invoice": {"-id": "3"}}}}`,
+		},
+		{
+			plugins:  []xml2json.Plugin{xml2json.IncludePaths("Envelope.Body.*"), xml2json.ExcludePaths("**.Order.Signature", "**.Invoice")},
+			expected: `{"Envelope": {"Body": {"Order": [{"-id": "1", "-ref": "a", "Line": {"-n": "1", "#content": "x"}}, {"-id": "2", "-ref": "b", "Line": {"-n": "2", "#content": "y"}}]}}}`,
+		},
+		{
+			plugins:  []xml2json.Plugin{xml2json.IncludePaths("Envelope.Missing")},
+			expected: `""`,
+		},
+	}
+
+	for _, scenario := range table {
+		plugins := append([]xml2json.Plugin{
+			xml2json.WithAttrPrefix("-"),
+			xml2json.WithContentPrefix("#"),
+			xml2json.WithComments("#comment"),
+		}, scenario.plugins...)
+		actual, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.JSONEq(scenario.expected, actual.String())
+	}
+
+	// Skipped subtrees are not kept but still within the limits
+	deep := "<doc><skip>" + strings.Repeat("<x>", 1000) + strings.Repeat("</x>", 1000) + "</skip><small>1</small></doc>"
+	_, err := xml2json.NewConverter(
+		xml2json.ExcludePaths("doc.skip"),
+		xml2json.WithLimits(xml2json.Limits{MaxDepth: 10}),
+	).Convert(strings.NewReader(deep))
+	var limitErr *xml2json.LimitError
+	t.Require().ErrorAs(err, &limitErr)
+	t.Equal(xml2json.LimitDepth, limitErr.Limit)
+
+	big := "<doc><big>" + strings.Repeat("<item/>", 100) + "</big><small>1</small></doc>"
+	_, err = xml2json.NewConverter(
+		xml2json.ExcludePaths("doc.big"),
+		xml2json.WithLimits(xml2json.Limits{MaxNodes: 50}),
+	).Convert(strings.NewReader(big))
+	t.Require().ErrorAs(err, &limitErr)
+	t.Equal(xml2json.LimitNodes, limitErr.Limit)
+
+	actual, err := xml2json.NewConverter(
+		xml2json.ExcludePaths("doc.skip"),
+		xml2json.WithLimits(xml2json.Limits{MaxDepth: 1002, MaxInputBytes: int64(len(deep))}),
+	).Convert(strings.NewReader(deep))
+	t.NoError(err)
+	t.JSONEq(`{"doc": {"small": "1"}}`, actual.String())
+
+	// The raw bytes of skipped subtrees are not recorded, CDATA sections after them are still told apart
+	cdata := `<doc><a><![CDATA[ 1 ]]></a>` +
+		`<skip><![CDATA[x]]><y> <![CDATA[ y ]]> </y>` + strings.Repeat("<x><![CDATA[ x ]]></x>", 1000) + `</skip>` +
+		`<b><![CDATA[ 2 ]]></b><c> 3 </c></doc>`
+	actual, err = xml2json.NewConverter(
+		xml2json.ExcludePaths("doc.skip"),
+		xml2json.WithCDATA(""),
+	).Convert(strings.NewReader(cdata))
+	t.NoError(err)
+	t.JSONEq(`{"doc": {"a": " 1 ", "b": " 2 ", "c": "3"}}`, actual.String())
+}
+
+type testOrderLine struct {
+	SKU      string  `json:"-sku"`
+	Quantity int     `json:"qty"`
+	Price    float64 `json:"price"`
+}
+
+type testOrder struct {
+	Order struct {
+		ID       string          `json:"-id"`
+		Paid     bool            `json:"paid"`
+		Zip      string          `json:"zip"`
+		Lines    []testOrderLine `json:"line"`
+		Tags     []string        `json:"tag"`
+		Comments *struct {
+			Text string `json:"#content"`
+			Lang string `json:"-lang"`
+		} `json:"comment"`
+	} `json:"order"`
+}
+
+func (t *TestConverter) TestConvertFromStruct() {
+	s := `<order id="0042"><paid>true</paid><zip>01234</zip>
+		<line sku="007"><qty>2</qty><price>10</price></line>
+		<tag>42</tag>
+		<comment lang="en">123</comment>
+	</order>`
+
+	plugins := []xml2json.Plugin{
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithContentPrefix("#"),
+		xml2json.WithTypeConverter(xml2json.Bool, xml2json.Int, xml2json.Float),
+		xml2json.AttrToArray("order.unknown"),
+		xml2json.FromStruct[testOrder](),
+	}
+	actual, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.JSONEq(`{"order": {
+		"-id": "0042", "paid": true, "zip": "01234",
+		"line": [{"-sku": "007", "qty": 2, "price": 10}],
+		"tag": ["42"],
+		"comment": {"-lang": "en", "#content": "123"}
+	}}`, actual.String())
+
+	var order testOrder
+	t.NoError(json.Unmarshal(actual.Bytes(), &order))
+	t.Equal("007", order.Order.Lines[0].SKU)
+
+	// Values which do not fit their field make the encoding fail
+	_, err = xml2json.NewConverter(plugins...).Convert(strings.NewReader(`<order><paid>yes</paid></order>`))
+	t.Error(err)
+
+	// Records
+	buf := new(bytes.Buffer)
+	err = xml2json.NewRecordConverter("order.line", xml2json.WithAttrPrefix("-"), xml2json.FromStructAt[testOrderLine]("order.line")).
+		Convert(strings.NewReader(s), buf)
+	t.NoError(err)
+	t.Equal(`{"-sku": "007", "qty": 2, "price": 10}`+"\n", buf.String())
+
+	// AttrToArray adds to the paths set by FromStruct
+	actual, err = xml2json.NewConverter(
+		xml2json.FromStruct[testOrder](),
+		xml2json.AttrToArray("order.zip"),
+	).Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.Contains(actual.String(), `"zip": ["01234"]`)
+	t.Contains(actual.String(), `"tag": ["42"]`)
+}
+
+func (t *TestConverter) TestConvertFromStructWithKeyTransform() {
+	type order struct {
+		Order struct {
+			Lines []struct {
+				Quantity int    `json:"qty"`
+				SKU      string `json:"sku"`
+			} `json:"orderLine"`
+			Total float64 `json:"total"`
+		} `json:"order"`
+	}
+
+	s := `<order><order-line><qty>01</qty><sku>007</sku></order-line><sum>12.50</sum></order>`
+	plugins := []xml2json.Plugin{
+		xml2json.FromStruct[order](),
+		xml2json.WithKeyTransform(xml2json.CamelCase),
+		xml2json.WithRenames(map[string]string{"order.sum": "total"}),
+	}
+	actual, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.JSONEq(`{"order": {"orderLine": [{"qty": 1, "sku": "007"}], "total": 12.5}}`, actual.String())
+
+	var v order
+	t.NoError(json.Unmarshal(actual.Bytes(), &v))
+
+	root := &xml2json.Node{}
+	t.Require().NoError(xml2json.NewDecoder(strings.NewReader(s)).Decode(root))
+	var unmarshalled order
+	t.NoError(root.Unmarshal(&unmarshalled, plugins...))
+	t.Equal(v, unmarshalled)
+}
@@ -1,6 +1,7 @@
 package xml2json
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -17,12 +18,55 @@ type Decoder struct {
 	attributePrefix string
 	contentPrefix   string
 	excludeAttrs    map[string]bool
+	mixedContent    MixedContentMode
+
+	namespaceMode         NamespaceMode
+	namespacePrefixes     map[string]string
+	namespaceDeclarations bool
+	// namespaceScope adds the namespaces in scope of every element, see BadgerFish
+	namespaceScope bool
+	dropAttributes bool
+
+	cdata          bool
+	commentKey     string
+	procInstPrefix string
+	limits         Limits
+
+	whitespace      WhitespaceMode
+	whitespacePaths pathMap[WhitespaceMode]
+	ignoreXMLSpace  bool
+
+	collisionPolicy CollisionPolicy
+	onCollision     func(Collision)
+
+	includePaths []pathPattern
+	excludePaths []pathPattern
+	// nodes is the number of elements and attributes read so far
+	nodes int
 }
 
 type element struct {
 	parent *element
 	n      *Node
 	label  string
+	path   string
+	depth  int
+	ns     map[string]string
+	// keep is false for elements outside of records, they are dropped as soon as decoded
+	keep   bool
+	record bool
+	// cdataRun tells that the last token of the element was a CDATA section
+	cdataRun bool
+	// cdataStart and cdataEnd delimit the CDATA sections within the concatenated text,
+	// which are kept as is when handling its whitespace
+	cdataStart int
+	cdataEnd   int
+	// preserveSpace is set by xml:space="preserve" on the element or its ancestors
+	preserveSpace bool
+	whitespace    WhitespaceMode
+	// partial is set on the elements kept by IncludePaths only because of their descendants,
+	// their text, comments and attributes outside of the included paths are dropped
+	partial bool
 }
 
 func (dec *Decoder) SetAttributePrefix(prefix string) {
@@ -62,77 +106,283 @@ func NewDecoder(reader io.Reader, plugins ...Plugin) *Decoder {
 // Decode reads the next JSON-encoded value from its
 // input and stores it in the value pointed to by v.
 func (dec *Decoder) Decode(root *Node) error {
-	xmlDec := xml.NewDecoder(dec.reader)
+	return dec.DecodeContext(context.Background(), root)
+}
+
+// DecodeContext is like Decode but stops as soon as the context is done
+func (dec *Decoder) DecodeContext(ctx context.Context, root *Node) error {
+	return dec.decode(ctx, root, "", nil)
+}
+
+// DecodeRecords decodes every element found at the given dotted path, e.g. "osm.node",
+// as a tree on its own and passes it to fn. Records are not linked to the document
+// and nothing outside of them is kept, so memory is bounded by the size of a single record.
+func (dec *Decoder) DecodeRecords(path string, fn func(record *Node) error) error {
+	return dec.DecodeRecordsContext(context.Background(), path, fn)
+}
+
+// DecodeRecordsContext is like DecodeRecords but stops as soon as the context is done
+func (dec *Decoder) DecodeRecordsContext(ctx context.Context, path string, fn func(record *Node) error) error {
+	return dec.decode(ctx, &Node{}, path, fn)
+}
+
+func (dec *Decoder) decode(ctx context.Context, root *Node, recordPath string, onRecord func(*Node) error) error {
+	var (
+		reader   io.Reader = contextReader{ctx: ctx, reader: dec.limitReader()}
+		recorder *rawRecorder
+	)
+	if dec.cdata {
+		recorder = newRawRecorder(reader)
+		reader = recorder
+	}
+
+	xmlDec := xml.NewDecoder(reader)
+	dec.nodes = 0
 
 	// That will convert the charset if the provided XML is non-UTF-8
 	xmlDec.CharsetReader = charset.NewReaderLabel
+	if recorder != nil {
+		xmlDec.CharsetReader = recorder.charsetReader
+	}
 
 	// Create first element from the root node
 	elem := &element{
-		parent: nil,
-		n:      root,
+		parent:     nil,
+		n:          root,
+		keep:       onRecord == nil,
+		whitespace: dec.whitespace,
+		partial:    dec.projected(),
 	}
 
-	for {
+	for tokens := 0; ; tokens++ {
+		if tokens%contextCheckInterval == 0 && ctx.Err() != nil {
+			return decodeError(xmlDec, elem, errors.WithMessagef(ctx.Err(), "decoding stopped after %d tokens", tokens))
+		}
+
+		start := xmlDec.InputOffset()
 		t, err := xmlDec.Token()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
+			if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+				return decodeError(xmlDec, elem, errors.WithMessagef(err, "decoding stopped after %d tokens", tokens))
+			}
 
-			return errors.WithMessage(err, "xml decoder token")
+			return decodeError(xmlDec, elem, errors.WithMessage(err, "xml decoder token"))
 		}
 
 		switch se := t.(type) {
 		case xml.StartElement:
-			// Build new a new current element and link it to its parent
-			elem = &element{
-				parent: elem,
-				n:      &Node{},
-				label:  se.Name.Local,
+			elem = dec.startElement(elem, se)
+			err := dec.checkElement(elem, se.Attr)
+			if err != nil {
+				return decodeError(xmlDec, elem, err)
 			}
-
-			// Extract attributes as children
-			for _, a := range se.Attr {
-				_, spaceFound := dec.excludeAttrs[a.Name.Space]
-				_, localFound := dec.excludeAttrs[a.Name.Local]
-				if spaceFound || localFound {
-					continue
+			if dec.skipElement(elem) {
+				// Excluded subtrees are read without being decoded
+				err := dec.skip(xmlDec, recorder, elem)
+				if err != nil {
+					return decodeError(xmlDec, elem, err)
+				}
+				elem = elem.parent
+				break
+			}
+			if onRecord != nil && !elem.keep && elem.path == recordPath {
+				elem.keep = true
+				elem.record = true
+			}
+			if elem.keep && !dec.dropAttributes {
+				dec.addAttributes(elem, se.Attr)
+				if dec.namespaceScope {
+					dec.addNamespaces(elem)
 				}
-
-				elem.n.AddChild(dec.attributePrefix+a.Name.Local, &Node{Data: a.Value})
 			}
 		case xml.CharData:
-			// Extract XML data (if any)
-			elem.n.Data = TrimNonGraphic(string(se))
+			err := dec.checkText(se)
+			if err != nil {
+				return decodeError(xmlDec, elem, err)
+			}
+
+			// Extract XML data (if any), CDATA sections are kept as is
+			cdata := recorder != nil && recorder.isCDATA(start, xmlDec.InputOffset())
+			if elem.partial {
+				// Only the included descendants are kept
+			} else if elem.keep {
+				dec.addText(elem, string(se), cdata)
+			}
+		case xml.Comment:
+			if elem.keep && !elem.partial && dec.commentKey != "" {
+				err := dec.checkText(se)
+				if err != nil {
+					return decodeError(xmlDec, elem, err)
+				}
+				dec.addChild(elem, dec.commentKey, &Node{Data: string(se), Type: CommentNode})
+			}
+		case xml.ProcInst:
+			// The XML declaration is not an instruction for applications
+			if elem.keep && !elem.partial && dec.procInstPrefix != "" && se.Target != xmlPrefix {
+				err := dec.checkText(se.Inst)
+				if err != nil {
+					return decodeError(xmlDec, elem, err)
+				}
+				dec.addChild(elem, dec.procInstPrefix+se.Target, &Node{Data: string(se.Inst), Type: ProcInstNode})
+			}
 		case xml.EndElement:
-			// And add it to its parent list
-			if elem.parent != nil {
-				elem.parent.n.AddChild(elem.label, elem.n)
+			if elem.keep && dec.mixedContent != MixedContentNone {
+				finishText(elem)
+			}
+			if elem.keep && elem.whitespace != WhitespaceTrim {
+				dropIndentation(elem.n)
+			}
+			if elem.keep {
+				err := dec.resolveCollisions(elem)
+				if err != nil {
+					return decodeError(xmlDec, elem, err)
+				}
+			}
+
+			if elem.record {
+				dec.setPath(elem.path, elem.n)
+				err := onRecord(elem.n)
+				if err != nil {
+					return decodeError(xmlDec, elem, errors.WithMessage(err, "handle record"))
+				}
+			} else if elem.keep && elem.parent != nil && (!elem.partial || len(elem.n.Children) > 0) {
+				// And add it to its parent list, unless nothing was included from it
+				dec.addChild(elem.parent, elem.label, elem.n)
 			}
 
 			// Then change the current element to its parent
 			elem = elem.parent
 		}
+
+		if recorder != nil {
+			recorder.forget(xmlDec.InputOffset())
+		}
 	}
 
-	dec.setPath("", root)
+	if onRecord == nil {
+		dec.setPath("", root)
+	}
 
 	return nil
 }
 
-func (dec *Decoder) setPath(path string, node *Node) {
-	node.Label = path
-	for label, nodes := range node.Children {
-		childPath := label
-		if path != "" {
-			childPath = fmt.Sprintf("%s.%s", path, childPath)
+// startElement builds a new current element and links it to its parent
+func (dec *Decoder) startElement(parent *element, se xml.StartElement) *element {
+	parent.cdataRun = false
+	elem := &element{
+		parent: parent,
+		n:      &Node{},
+		keep:   parent.keep,
+		depth:  parent.depth + 1,
+	}
+	if dec.namespaceMode != NamespaceStrip {
+		elem.ns = declarations(se.Attr)
+	}
+	elem.label = dec.name(elem, se.Name)
+	elem.path = joinPath(parent.path, elem.label)
+	elem.whitespace = dec.whitespaceMode(elem, se.Attr)
+
+	return elem
+}
+
+// addAttributes extracts attributes as children
+func (dec *Decoder) addAttributes(elem *element, attrs []xml.Attr) {
+	for _, a := range attrs {
+		_, spaceFound := dec.excludeAttrs[a.Name.Space]
+		_, localFound := dec.excludeAttrs[a.Name.Local]
+		if spaceFound || localFound {
+			continue
+		}
+
+		name := dec.attrName(elem, a)
+		if name == "" {
+			continue
 		}
 
-		for _, n := range nodes {
-			dec.setPath(childPath, n)
+		key := dec.attributePrefix + name
+		if dec.skipAttribute(elem, key) {
+			continue
 		}
+		elem.n.AddChild(key, &Node{Data: a.Value, Type: AttributeNode})
+	}
+}
+
+func (dec *Decoder) addChild(parent *element, label string, n *Node) {
+	parent.n.AddChild(label, n)
+	if dec.mixedContent == MixedContentParts {
+		parent.n.Segments = append(parent.n.Segments, Segment{Label: label, Node: n})
+	}
+}
+
+// addText adds a text run, CDATA sections being kept as is. The runs of mixed content are concatenated
+// as they appear in the document, their whitespace is handled once the element ends, see finishText
+func (dec *Decoder) addText(elem *element, text string, cdata bool) {
+	// Consecutive CDATA sections are parts of the same text, e.g. when splitting ]]>
+	continued := cdata && elem.cdataRun
+	elem.cdataRun = cdata
+
+	if dec.mixedContent == MixedContentNone {
+		if !cdata {
+			text = elem.whitespace.apply(text)
+		}
+		if text == "" && elem.n.CDATA {
+			// Keep the CDATA section over the blanks around it
+			return
+		}
+		if continued {
+			text = elem.n.Data + text
+		}
+		elem.n.Data = text
+		elem.n.CDATA = cdata
+		return
+	}
+
+	if cdata {
+		if !elem.n.CDATA {
+			elem.cdataStart = len(elem.n.Data)
+		}
+		elem.n.Data += text
+		elem.cdataEnd = len(elem.n.Data)
+		elem.n.CDATA = true
+	} else {
+		elem.n.Data += text
+		text = elem.whitespace.apply(text)
+	}
+
+	if dec.mixedContent != MixedContentParts || (text == "" && !cdata) {
+		return
+	}
+	if continued {
+		elem.n.Segments[len(elem.n.Segments)-1].Text += text
+	} else {
+		elem.n.Segments = append(elem.n.Segments, Segment{Text: text, CDATA: cdata})
+	}
+}
+
+// finishText handles the whitespace of the concatenated text of an element, but within its CDATA sections
+func finishText(elem *element) {
+	n := elem.n
+	if !n.CDATA {
+		n.Data = elem.whitespace.apply(n.Data)
+		return
+	}
+
+	before, after := elem.whitespace.applyAround(n.Data[:elem.cdataStart], n.Data[elem.cdataEnd:])
+	n.Data = before + n.Data[elem.cdataStart:elem.cdataEnd] + after
+}
+
+func (dec *Decoder) setPath(path string, node *Node) {
+	node.setLabels(path)
+}
+
+func joinPath(path string, label string) string {
+	if path == "" {
+		return label
 	}
+	return fmt.Sprintf("%s.%s", path, label)
 }
 
 // TrimNonGraphic returns a slice of the string s, with all leading and trailing
@@ -1,6 +1,8 @@
 package xml2json_test
 
 import (
+	"encoding/xml"
+	"errors"
 	"strings"
 	"testing"
 
@@ -93,3 +95,79 @@ func (t *TestDecoder) TestTrim() {
 		t.Equal(scenario.expected, got)
 	}
 }
+
+func (t *TestDecoder) TestDecodeMixedContentParts() {
+	root := &xml2json.Node{}
+	dec := xml2json.NewDecoder(
+		strings.NewReader(`<p id="1">Hello <b>big</b> world</p>`),
+		xml2json.WithMixedContent(xml2json.MixedContentParts),
+	)
+	err := dec.Decode(root)
+	t.NoError(err)
+
+	p := root.GetChild("p")
+	t.True(p.IsMixed())
+	t.Equal("Hello  world", p.Data)
+	t.Equal(xml2json.AttributeNode, p.Children["id"][0].Type)
+	t.Require().Len(p.Segments, 3)
+	t.Equal("Hello", p.Segments[0].Text)
+	t.Equal("b", p.Segments[1].Label)
+	t.Same(p.Children["b"][0], p.Segments[1].Node)
+	t.Equal("world", p.Segments[2].Text)
+}
+
+func (t *TestDecoder) TestDecodeWithLimits() {
+	table := []struct {
+		limits   xml2json.Limits
+		expected xml2json.Limit
+	}{
+		{limits: xml2json.Limits{MaxDepth: 2}, expected: xml2json.LimitDepth},
+		{limits: xml2json.Limits{MaxNodes: 20}, expected: xml2json.LimitNodes},
+		{limits: xml2json.Limits{MaxAttributes: 8}, expected: xml2json.LimitAttributes},
+		{limits: xml2json.Limits{MaxTextBytes: 10}, expected: xml2json.LimitTextBytes},
+		{limits: xml2json.Limits{MaxInputBytes: 100}, expected: xml2json.LimitInputBytes},
+	}
+
+	for _, scenario := range table {
+		dec := xml2json.NewDecoder(strings.NewReader(t.source), xml2json.WithLimits(scenario.limits))
+		err := dec.Decode(&xml2json.Node{})
+
+		var limitErr *xml2json.LimitError
+		t.Require().True(errors.As(err, &limitErr), "%v", err)
+		t.Equal(scenario.expected, limitErr.Limit)
+	}
+
+	limits := xml2json.Limits{
+		MaxDepth:      3,
+		MaxNodes:      45,
+		MaxAttributes: 9,
+		MaxTextBytes:  20,
+		MaxInputBytes: int64(len(t.source)),
+	}
+	dec := xml2json.NewDecoder(strings.NewReader(t.source), xml2json.WithLimits(limits))
+	err := dec.Decode(&xml2json.Node{})
+	t.NoError(err)
+}
+
+func (t *TestDecoder) TestDecodeError() {
+	s := "<osm>\n  <node id=\"1\">\n    <tag/></way>\n</osm>"
+
+	err := xml2json.NewDecoder(strings.NewReader(s)).Decode(&xml2json.Node{})
+
+	var decodeErr *xml2json.DecodeError
+	t.Require().True(errors.As(err, &decodeErr), "%v", err)
+	t.Equal(3, decodeErr.Line)
+	t.Equal(17, decodeErr.Column)
+	t.EqualValues(len("<osm>\n  <node id=\"1\">\n    <tag/></way>"), decodeErr.Offset)
+	t.Equal("osm.node", decodeErr.Path)
+
+	var syntaxErr *xml.SyntaxError
+	t.True(errors.As(err, &syntaxErr))
+	t.Contains(err.Error(), "line 3, column 17")
+
+	err = xml2json.NewDecoder(strings.NewReader(s), xml2json.WithLimits(xml2json.Limits{MaxDepth: 2})).Decode(&xml2json.Node{})
+	t.Require().True(errors.As(err, &decodeErr), "%v", err)
+	t.Equal("osm.node.tag", decodeErr.Path)
+	var limitErr *xml2json.LimitError
+	t.True(errors.As(err, &limitErr))
+}
@@ -2,6 +2,7 @@ package xml2json
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"unicode/utf8"
 
@@ -13,10 +14,38 @@ type Encoder struct {
 	writer              io.Writer
 	err                 error
 	contentPrefix       string
+	contentKeyName      string
 	attributePrefix     string
 	tc                  encoderTypeConverter
 	allAttributeToArray bool
 	attrIsAlwaysAnArray map[string]bool
+	mixedContent        MixedContentMode
+	indentPrefix        string
+	indent              string
+	compact             bool
+	typeHints           pathMap[JSType]
+	cdataKey            string
+	alwaysObject        bool
+	stripRoot           bool
+	dropAttributes      bool
+	dropMixedText       bool
+	emptyAsNull         bool
+	jsonML              bool
+	keyTransform        KeyTransform
+	renames             pathMap[string]
+	commentKey          string
+	procInstPrefix      string
+
+	// structTypes set structTypeHints and structArrays, keyed by json paths, see FromStruct
+	structTypes     []structType
+	structTypeHints map[string]JSType
+	structArrays    map[string]bool
+	// outputPaths caches the json paths of labels
+	outputPaths map[outputPathKey]string
+
+	ctx context.Context
+	// nodes is the number of nodes written by the current call to Encode
+	nodes int
 }
 
 // NewEncoder returns a new encoder that writes to writer.
@@ -34,6 +63,11 @@ func NewEncoder(writer io.Writer, plugins ...Plugin) *Encoder {
 
 // Encode writes the JSON encoding of v to the stream
 func (enc *Encoder) Encode(root *Node) error {
+	return enc.EncodeContext(context.Background(), root)
+}
+
+// EncodeContext is like Encode but stops as soon as the context is done
+func (enc *Encoder) EncodeContext(ctx context.Context, root *Node) error {
 	if enc.err != nil {
 		return enc.err
 	}
@@ -41,7 +75,19 @@ func (enc *Encoder) Encode(root *Node) error {
 		return nil
 	}
 
-	enc.err = enc.format(root, 0)
+	if enc.stripRoot && root.Label == "" {
+		_, root = documentElement(root)
+	}
+
+	enc.ctx = ctx
+	enc.nodes = 0
+	enc.setStructHints()
+	if enc.jsonML {
+		enc.err = enc.formatJsonMLRoot(root)
+	} else {
+		enc.err = enc.format(root, 0)
+	}
+	enc.ctx = nil
 
 	// Terminate each value with a newline.
 	// This makes the output look a little nicer
@@ -55,70 +101,72 @@ func (enc *Encoder) Encode(root *Node) error {
 }
 
 func (enc *Encoder) format(n *Node, lvl int) error {
-	if n.IsComplex() {
+	if enc.nodes%contextCheckInterval == 0 && enc.ctx != nil && enc.ctx.Err() != nil {
+		return errors.WithMessagef(enc.ctx.Err(), "encoding stopped after %d nodes", enc.nodes)
+	}
+	enc.nodes++
+
+	if n.IsComplex() || enc.isCDATA(n) || (enc.alwaysObject && n.Type == ElementNode) {
 		enc.write("{")
 
-		// Add data as an additional attibute (if any)
-		if len(n.Data) > 0 {
-			enc.write("\"")
-			enc.write(enc.contentPrefix)
-			enc.write("content")
-			if enc.allAttributeToArray {
-				enc.write("\": [")
-				enc.write(sanitiseString(n.Data))
-				enc.write("], ")
-			} else {
-				enc.write("\": ")
-				enc.write(sanitiseString(n.Data))
-				enc.write(", ")
-			}
+		keys := n.ChildKeys()
+		if enc.dropAttributes {
+			keys = keysOfType(n, keys, ElementNode, CommentNode, ProcInstNode)
 		}
 
-		i := 0
-		tot := len(n.Children)
-		for label, children := range n.Children {
-			enc.write("\"")
-			enc.write(label)
-			enc.write("\": ")
-
-			if enc.allAttributeToArray || len(children) > 1 {
-				// Array
-				enc.write("[")
-				for j, c := range children {
-					err := enc.format(c, lvl+1)
-					if err != nil {
-						return errors.WithMessagef(err, "format %s children", label)
-					}
-
-					if j < len(children)-1 {
-						enc.write(", ")
-					}
-				}
-				enc.write("]")
-			} else {
-				child := children[0]
-				attrIsArray := enc.attrIsAlwaysAnArray[child.Label]
-				if attrIsArray {
-					enc.write("[")
-				}
-				// Map
-				err := enc.format(child, lvl+1)
-				if err != nil {
-					return errors.WithMessagef(err, "format %s children", label)
-				}
-
-				if attrIsArray {
-					enc.write("]")
-				}
+		members := 0
+		if enc.dropMixedText {
+			// Text is only written for elements without children
+		} else if enc.mixedContent == MixedContentParts && n.IsMixed() {
+			// Child elements are written within the content parts
+			enc.newline(lvl + 1)
+			err := enc.formatParts(n, lvl+1)
+			if err != nil {
+				return err
 			}
 
-			if i < tot-1 {
-				enc.write(", ")
+			keys = keysOutsideSegments(keys, n.Segments)
+			members++
+		} else if len(n.Data) > 0 || enc.isCDATA(n) {
+			// Add data as an additional attibute (if any)
+			enc.newline(lvl + 1)
+			err := enc.formatContent(n, lvl+1)
+			if err != nil {
+				return err
 			}
-			i++
+			members++
 		}
 
+		err := enc.checkKeys(n, keys)
+		if err != nil {
+			return err
+		}
+		for _, label := range keys {
+			if members > 0 {
+				enc.writeComma()
+			}
+			enc.newline(lvl + 1)
+			err := enc.formatChildren(label, n.Children[label], lvl+1)
+			if err != nil {
+				return err
+			}
+			members++
+		}
+
+		if members > 0 {
+			enc.newline(lvl)
+		}
 		enc.write("}")
+	} else if n.Type == CommentNode || n.Type == ProcInstNode {
+		enc.write(sanitiseString(n.Data))
+	} else if enc.emptyAsNull && n.Type == ElementNode && n.Data == "" {
+		enc.write("null")
+	} else if hint, ok := enc.typeHint(n); ok {
+		s, err := hint.encode(n.Data)
+		if err != nil {
+			return errors.WithMessagef(err, "format %s", n.Label)
+		}
+		enc.write(s)
 	} else {
 		s := sanitiseString(n.Data)
 		if enc.tc == nil {
@@ -133,6 +181,227 @@ func (enc *Encoder) format(n *Node, lvl int) error {
 	return nil
 }
 
+// formatContent writes the data of a node as a member of its object, lvl being the member level
+func (enc *Encoder) formatContent(n *Node, lvl int) error {
+	key := enc.contentKey()
+	content := sanitiseString(n.Data)
+	if enc.isCDATA(n) {
+		key = enc.cdataKey
+	} else if hint, ok := enc.typeHint(n); ok {
+		var err error
+		content, err = hint.encode(n.Data)
+		if err != nil {
+			return errors.WithMessagef(err, "format %s content", n.Label)
+		}
+	}
+
+	enc.writeKey(key)
+	if enc.allAttributeToArray {
+		enc.write("[")
+		enc.newline(lvl + 1)
+		enc.write(content)
+		enc.newline(lvl)
+		enc.write("]")
+	} else {
+		enc.write(content)
+	}
+
+	return nil
+}
+
+// isCDATA returns whether the data of the node must be written under the CDATA key
+func (enc *Encoder) isCDATA(n *Node) bool {
+	return n.CDATA && enc.cdataKey != ""
+}
+
+// formatChildren writes a member of an object holding the given children, lvl being the member level
+func (enc *Encoder) formatChildren(label string, children Nodes, lvl int) error {
+	enc.writeKey(enc.outputKey(label, children[0]))
+
+	if enc.allAttributeToArray || len(children) > 1 {
+		// Array
+		enc.write("[")
+		for j, c := range children {
+			enc.newline(lvl + 1)
+			err := enc.format(c, lvl+1)
+			if err != nil {
+				return errors.WithMessagef(err, "format %s children", label)
+			}
+
+			if j < len(children)-1 {
+				enc.writeComma()
+			}
+		}
+		enc.newline(lvl)
+		enc.write("]")
+	} else {
+		child := children[0]
+		attrIsArray := enc.attrIsAlwaysAnArray[child.Label] || enc.structArrays[enc.outputPath(child.Label, child.Type)]
+		childLvl := lvl
+		if attrIsArray {
+			enc.write("[")
+			childLvl++
+			enc.newline(childLvl)
+		}
+		// Map
+		err := enc.format(child, childLvl)
+		if err != nil {
+			return errors.WithMessagef(err, "format %s children", label)
+		}
+
+		if attrIsArray {
+			enc.newline(lvl)
+			enc.write("]")
+		}
+	}
+
+	return nil
+}
+
+// formatParts writes the text runs and child elements of a mixed node as a single array,
+// each element being wrapped into its own object
+func (enc *Encoder) formatParts(n *Node, lvl int) error {
+	enc.writeKey(enc.contentKey())
+	enc.write("[")
+	for i, s := range n.Segments {
+		enc.newline(lvl + 1)
+		if s.IsText() && s.CDATA && enc.cdataKey != "" {
+			enc.write("{")
+			enc.newline(lvl + 2)
+			enc.writeKey(enc.cdataKey)
+			enc.write(sanitiseString(s.Text))
+			enc.newline(lvl + 1)
+			enc.write("}")
+		} else if s.IsText() {
+			enc.write(sanitiseString(s.Text))
+		} else {
+			enc.write("{")
+			enc.newline(lvl + 2)
+			err := enc.formatChildren(s.Label, Nodes{s.Node}, lvl+2)
+			if err != nil {
+				return err
+			}
+			enc.newline(lvl + 1)
+			enc.write("}")
+		}
+
+		if i < len(n.Segments)-1 {
+			enc.writeComma()
+		}
+	}
+	enc.newline(lvl)
+	enc.write("]")
+
+	return nil
+}
+
+// keysOfType returns the keys holding nodes of the given types
+func keysOfType(n *Node, keys []string, types ...NodeType) []string {
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, t := range types {
+			if n.Children[k][0].Type == t {
+				result = append(result, k)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// documentElement returns the single element of a document node and its key, or the node itself
+func documentElement(root *Node) (string, *Node) {
+	var (
+		key     string
+		element *Node
+	)
+	for _, k := range keysOfType(root, root.ChildKeys(), ElementNode) {
+		children := root.Children[k]
+		if element != nil || len(children) > 1 {
+			return "", root
+		}
+		key, element = k, children[0]
+	}
+
+	if element == nil {
+		return "", root
+	}
+	return key, element
+}
+
+func keysOutsideSegments(keys []string, segments []Segment) []string {
+	inSegments := make(map[string]bool, len(segments))
+	for _, s := range segments {
+		if !s.IsText() {
+			inSegments[s.Label] = true
+		}
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if !inSegments[k] {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// contentKey returns the key holding the text of complex nodes
+func (enc *Encoder) contentKey() string {
+	if enc.contentKeyName != "" {
+		return enc.contentKeyName
+	}
+	return enc.contentPrefix + "content"
+}
+
+// SetIndent instructs the encoder to format each subsequent encoded value as if indented
+// by the package-level function json.Indent(dst, src, prefix, indent).
+// Calling SetIndent("", "") disables indentation.
+func (enc *Encoder) SetIndent(prefix string, indent string) {
+	enc.indentPrefix = prefix
+	enc.indent = indent
+}
+
+// SetCompact instructs the encoder to leave out the spaces after commas and colons
+// when values are not indented
+func (enc *Encoder) SetCompact(compact bool) {
+	enc.compact = compact
+}
+
+func (enc *Encoder) indented() bool {
+	return enc.indentPrefix != "" || enc.indent != ""
+}
+
+// newline starts a new line indented at the given level, when indentation is enabled
+func (enc *Encoder) newline(lvl int) {
+	if !enc.indented() {
+		return
+	}
+
+	enc.write("\n")
+	enc.write(enc.indentPrefix)
+	for i := 0; i < lvl; i++ {
+		enc.write(enc.indent)
+	}
+}
+
+func (enc *Encoder) writeComma() {
+	if enc.compact || enc.indented() {
+		enc.write(",")
+	} else {
+		enc.write(", ")
+	}
+}
+
+func (enc *Encoder) writeKey(key string) {
+	enc.write(sanitiseString(key))
+	if enc.compact && !enc.indented() {
+		enc.write(":")
+	} else {
+		enc.write(": ")
+	}
+}
+
 func (enc *Encoder) write(s string) {
 	enc.writer.Write([]byte(s))
 }
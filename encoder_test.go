@@ -3,6 +3,7 @@ package xml2json_test
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -117,3 +118,49 @@ func (t *TestEncoder) TestEncode() {
 
 	t.EqualValues(expectedResult, actualResult)
 }
+
+// TestEncodeDocumentOrder ensures that keys are written in the order they were added.
+func (t *TestEncoder) TestEncodeDocumentOrder() {
+	root := &xml2json.Node{}
+	for _, label := range []string{"zulu", "alpha", "mike", "alpha", "bravo"} {
+		root.AddChild(label, &xml2json.Node{Data: label})
+	}
+
+	for i := 0; i < 10; i++ {
+		buf := new(bytes.Buffer)
+		err := xml2json.NewEncoder(buf).Encode(root)
+		t.NoError(err)
+		t.Equal(`{"zulu": "zulu", "alpha": ["alpha", "alpha"], "mike": "mike", "bravo": "bravo"}`+"\n", buf.String())
+	}
+}
+
+// TestEncodeIndent ensures that indented and compact outputs match the ones of encoding/json
+func (t *TestEncoder) TestEncodeIndent() {
+	s := `<osm version="0.6"><node id="1"><tag k="name"/><tag k="ref"/></node><p>Hello <b>big</b> world</p><foo>bar</foo></osm>`
+
+	table := [][]xml2json.Plugin{
+		{xml2json.WithAttrPrefix("-")},
+		{xml2json.AllAttrToArray()},
+		{xml2json.AttrToArray("osm.foo", "osm.node")},
+		{xml2json.WithMixedContent(xml2json.MixedContentParts)},
+	}
+
+	for _, plugins := range table {
+		raw, err := xml2json.NewConverter(plugins...).Convert(strings.NewReader(s))
+		t.NoError(err)
+
+		expected := new(bytes.Buffer)
+		err = json.Indent(expected, raw.Bytes(), ">", "\t")
+		t.NoError(err)
+		indented, err := xml2json.NewConverter(append(plugins, xml2json.WithIndent(">", "\t"))...).Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.Equal(expected.String(), indented.String())
+
+		expected.Reset()
+		err = json.Compact(expected, raw.Bytes())
+		t.NoError(err)
+		compact, err := xml2json.NewConverter(append(plugins, xml2json.WithCompact())...).Convert(strings.NewReader(s))
+		t.NoError(err)
+		t.Equal(expected.String()+"\n", compact.String())
+	}
+}
@@ -0,0 +1,42 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// DecodeError is returned when decoding a document fails, it tells where the decoder stopped
+type DecodeError struct {
+	// Offset is the number of bytes read from the document
+	Offset int64
+	// Line and Column are 1-based
+	Line   int
+	Column int
+	// Path is the dotted path of the current element, see Node.Label
+	Path string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "document root"
+	}
+	return fmt.Sprintf("line %d, column %d (byte %d) in %s: %v", e.Line, e.Column, e.Offset, path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeError wraps err with the current position of the decoder
+func decodeError(xmlDec *xml.Decoder, elem *element, err error) error {
+	line, column := xmlDec.InputPos()
+	return &DecodeError{
+		Offset: xmlDec.InputOffset(),
+		Line:   line,
+		Column: column,
+		Path:   elem.path,
+		Err:    err,
+	}
+}
@@ -0,0 +1,297 @@
+package xml2json
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A JSONDecoder reads JSON documents produced by an Encoder back into a tree of nodes.
+// It understands the conventions set by the same plugins: keys starting with the attribute prefix
+// are attributes, the content key holds the text and arrays are repeated elements.
+// Without an attribute prefix every key is decoded as an element.
+type JSONDecoder struct {
+	reader          io.Reader
+	attributePrefix string
+	contentKey      string
+	cdataKey        string
+	commentKey      string
+	procInstPrefix  string
+}
+
+// NewJSONDecoder returns a new decoder that reads from reader.
+func NewJSONDecoder(reader io.Reader, plugins ...Plugin) *JSONDecoder {
+	enc := NewEncoder(io.Discard, plugins...)
+	return &JSONDecoder{
+		reader:          reader,
+		attributePrefix: enc.attributePrefix,
+		contentKey:      enc.contentKey(),
+		cdataKey:        enc.cdataKey,
+		commentKey:      enc.commentKey,
+		procInstPrefix:  enc.procInstPrefix,
+	}
+}
+
+// Decode reads a JSON object from its input and stores its members as children of root
+func (dec *JSONDecoder) Decode(root *Node) error {
+	jsonDec := json.NewDecoder(dec.reader)
+	jsonDec.UseNumber()
+
+	t, err := jsonDec.Token()
+	if err != nil {
+		return errors.WithMessage(err, "json decoder token")
+	}
+	if t != json.Delim('{') {
+		return errors.Errorf("unexpected %v, document must be an object", t)
+	}
+
+	err = dec.decodeObject(jsonDec, root)
+	if err != nil {
+		return err
+	}
+
+	root.setLabels("")
+
+	return nil
+}
+
+// decodeObject decodes the members of an object whose opening brace has been read
+func (dec *JSONDecoder) decodeObject(jsonDec *json.Decoder, n *Node) error {
+	for jsonDec.More() {
+		t, err := jsonDec.Token()
+		if err != nil {
+			return errors.WithMessage(err, "json decoder token")
+		}
+		key := t.(string)
+
+		switch {
+		case key == dec.contentKey:
+			err = dec.decodeContent(jsonDec, n)
+		case dec.cdataKey != "" && key == dec.cdataKey:
+			err = dec.decodeContent(jsonDec, n)
+			n.CDATA = true
+		case dec.commentKey != "" && key == dec.commentKey:
+			err = dec.decodeMarkup(jsonDec, n, key, CommentNode)
+		case dec.procInstPrefix != "" && strings.HasPrefix(key, dec.procInstPrefix):
+			err = dec.decodeMarkup(jsonDec, n, key, ProcInstNode)
+		case dec.attributePrefix != "" && strings.HasPrefix(key, dec.attributePrefix):
+			err = dec.decodeAttribute(jsonDec, n, key)
+		default:
+			err = dec.decodeElements(jsonDec, n, key)
+		}
+		if err != nil {
+			return errors.WithMessagef(err, "decode %s", key)
+		}
+	}
+
+	// Consume the closing brace
+	_, err := jsonDec.Token()
+	if err != nil {
+		return errors.WithMessage(err, "json decoder token")
+	}
+
+	return nil
+}
+
+// decodeElements decodes a value as an element, or as repeated elements if it is an array
+func (dec *JSONDecoder) decodeElements(jsonDec *json.Decoder, parent *Node, key string) error {
+	t, err := jsonDec.Token()
+	if err != nil {
+		return errors.WithMessage(err, "json decoder token")
+	}
+
+	if t != json.Delim('[') {
+		return dec.decodeElement(jsonDec, parent, key, t)
+	}
+
+	for jsonDec.More() {
+		t, err := jsonDec.Token()
+		if err != nil {
+			return errors.WithMessage(err, "json decoder token")
+		}
+		if t == json.Delim('[') {
+			return errors.New("nested arrays are not supported")
+		}
+
+		err = dec.decodeElement(jsonDec, parent, key, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Consume the closing bracket
+	_, err = jsonDec.Token()
+	if err != nil {
+		return errors.WithMessage(err, "json decoder token")
+	}
+
+	return nil
+}
+
+// decodeElement decodes an element whose first token has been read
+func (dec *JSONDecoder) decodeElement(jsonDec *json.Decoder, parent *Node, key string, t json.Token) error {
+	n := &Node{}
+	if t == json.Delim('{') {
+		err := dec.decodeObject(jsonDec, n)
+		if err != nil {
+			return err
+		}
+	} else {
+		n.Data = scalar(t)
+	}
+
+	parent.AddChild(key, n)
+	return nil
+}
+
+// decodeAttribute decodes a scalar value, or an array holding a single scalar, as an attribute
+func (dec *JSONDecoder) decodeAttribute(jsonDec *json.Decoder, n *Node, key string) error {
+	values, err := dec.decodeScalars(jsonDec)
+	if err != nil {
+		return err
+	}
+	if len(values) != 1 {
+		return errors.New("attribute arrays must hold a single value")
+	}
+
+	n.AddChild(key, &Node{Data: values[0], Type: AttributeNode})
+	return nil
+}
+
+// decodeMarkup decodes a scalar value, or an array of scalars, as comments or processing instructions
+func (dec *JSONDecoder) decodeMarkup(jsonDec *json.Decoder, n *Node, key string, t NodeType) error {
+	values, err := dec.decodeScalars(jsonDec)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range values {
+		n.AddChild(key, &Node{Data: v, Type: t})
+	}
+	return nil
+}
+
+// decodeScalars decodes a scalar value or an array of scalars
+func (dec *JSONDecoder) decodeScalars(jsonDec *json.Decoder) ([]string, error) {
+	t, err := jsonDec.Token()
+	if err != nil {
+		return nil, errors.WithMessage(err, "json decoder token")
+	}
+
+	if t != json.Delim('[') {
+		if _, isDelim := t.(json.Delim); isDelim {
+			return nil, errors.Errorf("unexpected %v, expecting a scalar", t)
+		}
+		return []string{scalar(t)}, nil
+	}
+
+	var values []string
+	for jsonDec.More() {
+		t, err := jsonDec.Token()
+		if err != nil {
+			return nil, errors.WithMessage(err, "json decoder token")
+		}
+		if _, isDelim := t.(json.Delim); isDelim {
+			return nil, errors.Errorf("unexpected %v, expecting a scalar", t)
+		}
+		values = append(values, scalar(t))
+	}
+
+	// Consume the closing bracket
+	_, err = jsonDec.Token()
+	if err != nil {
+		return nil, errors.WithMessage(err, "json decoder token")
+	}
+
+	return values, nil
+}
+
+// decodeContent decodes the text of an element. An array holds either a single text
+// or the text runs and child elements of mixed content, see MixedContentParts
+func (dec *JSONDecoder) decodeContent(jsonDec *json.Decoder, n *Node) error {
+	t, err := jsonDec.Token()
+	if err != nil {
+		return errors.WithMessage(err, "json decoder token")
+	}
+
+	if t != json.Delim('[') {
+		if _, isDelim := t.(json.Delim); isDelim {
+			return errors.Errorf("unexpected %v, content must be a scalar or an array", t)
+		}
+		n.Data = scalar(t)
+		return nil
+	}
+
+	var (
+		segments []Segment
+		texts    []string
+		mixed    bool
+	)
+	for jsonDec.More() {
+		t, err := jsonDec.Token()
+		if err != nil {
+			return errors.WithMessage(err, "json decoder token")
+		}
+
+		switch t {
+		case json.Delim('{'):
+			// Elements are wrapped into their own object
+			part := &Node{}
+			err = dec.decodeObject(jsonDec, part)
+			if err != nil {
+				return err
+			}
+			if part.CDATA {
+				texts = append(texts, part.Data)
+				segments = append(segments, Segment{Text: part.Data, CDATA: true})
+				n.CDATA = true
+				mixed = true
+			}
+			for _, key := range part.ChildKeys() {
+				for _, c := range part.Children[key] {
+					n.AddChild(key, c)
+					segments = append(segments, Segment{Label: key, Node: c})
+					mixed = true
+				}
+			}
+		case json.Delim('['):
+			return errors.New("nested arrays are not supported")
+		default:
+			text := scalar(t)
+			texts = append(texts, text)
+			segments = append(segments, Segment{Text: text})
+		}
+	}
+
+	// Consume the closing bracket
+	_, err = jsonDec.Token()
+	if err != nil {
+		return errors.WithMessage(err, "json decoder token")
+	}
+
+	n.Data = strings.Join(texts, "")
+	if mixed {
+		n.Segments = segments
+	}
+
+	return nil
+}
+
+// scalar returns the text of a JSON scalar, null being an empty text
+func scalar(t json.Token) string {
+	switch v := t.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	default:
+		return ""
+	}
+}
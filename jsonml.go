@@ -0,0 +1,126 @@
+package xml2json
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// jsonMLComment is the tag of comments, e.g. ["!", " note "]
+	jsonMLComment = "!"
+	// jsonMLProcInst prefixes the target of processing instructions, e.g. ["?php", "echo 1;"]
+	jsonMLProcInst = "?"
+)
+
+// formatJsonMLRoot writes a document node, or any other node, as a JsonML element
+func (enc *Encoder) formatJsonMLRoot(root *Node) error {
+	if root.Label != "" {
+		segments := splitPath(root.Label)
+		return enc.formatJsonML(segments[len(segments)-1], root, 0)
+	}
+
+	tag, element := documentElement(root)
+	if element == root {
+		return errors.New("document must hold a single element")
+	}
+	return enc.formatJsonML(tag, element, 0)
+}
+
+// formatJsonML writes an element as ["tag", {"attr": "value"}, child...]
+func (enc *Encoder) formatJsonML(tag string, n *Node, lvl int) error {
+	if enc.nodes%contextCheckInterval == 0 && enc.ctx != nil && enc.ctx.Err() != nil {
+		return errors.WithMessagef(enc.ctx.Err(), "encoding stopped after %d nodes", enc.nodes)
+	}
+	enc.nodes++
+
+	enc.write("[")
+	enc.newline(lvl + 1)
+	enc.write(sanitiseString(enc.outputKey(tag, n)))
+
+	attrs := keysOfType(n, n.ChildKeys(), AttributeNode)
+	err := enc.checkKeys(n, attrs)
+	if err != nil {
+		return err
+	}
+	if len(attrs) > 0 {
+		enc.writeComma()
+		enc.newline(lvl + 1)
+		enc.write("{")
+		for i, key := range attrs {
+			if i > 0 {
+				enc.writeComma()
+			}
+			enc.newline(lvl + 2)
+			enc.writeKey(strings.TrimPrefix(enc.outputKey(key, n.Children[key][0]), enc.attributePrefix))
+			enc.write(sanitiseString(n.Children[key][0].Data))
+		}
+		enc.newline(lvl + 1)
+		enc.write("}")
+	}
+
+	for _, s := range jsonMLSegments(n) {
+		enc.writeComma()
+		enc.newline(lvl + 1)
+		if s.IsText() {
+			enc.write(sanitiseString(s.Text))
+			continue
+		}
+		switch s.Node.Type {
+		case CommentNode:
+			enc.formatJsonMLMarkup(jsonMLComment, s.Node.Data, lvl+1)
+			continue
+		case ProcInstNode:
+			enc.formatJsonMLMarkup(jsonMLProcInst+strings.TrimPrefix(s.Label, enc.procInstPrefix), s.Node.Data, lvl+1)
+			continue
+		}
+
+		err := enc.formatJsonML(s.Label, s.Node, lvl+1)
+		if err != nil {
+			return errors.WithMessagef(err, "format %s", s.Label)
+		}
+	}
+
+	enc.newline(lvl)
+	enc.write("]")
+
+	return nil
+}
+
+// formatJsonMLMarkup writes a comment or a processing instruction as [tag, data]
+func (enc *Encoder) formatJsonMLMarkup(tag string, data string, lvl int) {
+	enc.write("[")
+	enc.newline(lvl + 1)
+	enc.write(sanitiseString(tag))
+	if data != "" {
+		enc.writeComma()
+		enc.newline(lvl + 1)
+		enc.write(sanitiseString(data))
+	}
+	enc.newline(lvl)
+	enc.write("]")
+}
+
+// jsonMLSegments returns the text, child elements, comments and processing instructions
+// of a node in document order. Without segments, the text comes before the children
+func jsonMLSegments(n *Node) []Segment {
+	var segments []Segment
+	if n.Segments != nil {
+		for _, s := range n.Segments {
+			if s.IsText() || s.Node.Type != AttributeNode {
+				segments = append(segments, s)
+			}
+		}
+		return segments
+	}
+
+	if n.Data != "" {
+		segments = append(segments, Segment{Text: n.Data})
+	}
+	for _, key := range keysOfType(n, n.ChildKeys(), ElementNode, CommentNode, ProcInstNode) {
+		for _, c := range n.Children[key] {
+			segments = append(segments, Segment{Label: key, Node: c})
+		}
+	}
+	return segments
+}
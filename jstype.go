@@ -1,8 +1,11 @@
 package xml2json
 
 import (
+	"encoding/json"
 	"strconv"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 // https://cswr.github.io/JsonSchema/spec/basic_types/
@@ -72,3 +75,43 @@ func isInt(s string) bool {
 func isNull(s string) bool {
 	return s == "null"
 }
+
+// encode returns the JSON encoding of s as a value of type t.
+// Empty strings are encoded as null for other types than String
+func (t JSType) encode(s string) (string, error) {
+	if t == String {
+		return sanitiseString(s), nil
+	}
+
+	s = strings.TrimSpace(s)
+	if s == "" || t == Null {
+		return "null", nil
+	}
+
+	switch t {
+	case Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return "", errors.Errorf("%q is not a bool", s)
+		}
+		return strconv.FormatBool(b), nil
+	case Int:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return "", errors.Errorf("%q is not an int", s)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case Float:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return "", errors.Errorf("%q is not a float", s)
+		}
+		b, err := json.Marshal(f)
+		if err != nil {
+			return "", errors.Errorf("%q is not a float", s)
+		}
+		return string(b), nil
+	default:
+		return "", errors.Errorf("unknown type %d", t)
+	}
+}
@@ -0,0 +1,179 @@
+package xml2json
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A KeyTransform rewrites the names of elements and attributes into JSON keys, see WithKeyTransform
+type KeyTransform func(name string) string
+
+var (
+	// CamelCase turns Order-Line_Item into orderLineItem
+	CamelCase KeyTransform = camelCase
+	// SnakeCase turns Order-Line_Item into order_line_item
+	SnakeCase KeyTransform = func(name string) string {
+		return strings.ToLower(strings.Join(words(name), "_"))
+	}
+	// KebabCase turns Order-Line_Item into order-line-item
+	KebabCase KeyTransform = func(name string) string {
+		return strings.ToLower(strings.Join(words(name), "-"))
+	}
+	// LowerCase turns Order-Line_Item into order-line_item
+	LowerCase KeyTransform = strings.ToLower
+)
+
+// KeyCollisionError is returned when encoding with a key transform or renames
+// which give the same key to different names of an element
+type KeyCollisionError struct {
+	// Path is the dotted path of the element, see Node.Label
+	Path  string
+	Key   string
+	Names []string
+}
+
+func (e *KeyCollisionError) Error() string {
+	return fmt.Sprintf("names %q share key %q in %s", e.Names, e.Key, e.Path)
+}
+
+func camelCase(name string) string {
+	var b strings.Builder
+	for i, w := range words(name) {
+		w = strings.ToLower(w)
+		if i > 0 {
+			r, size := utf8.DecodeRuneInString(w)
+			b.WriteRune(unicode.ToUpper(r))
+			w = w[size:]
+		}
+		b.WriteString(w)
+	}
+	return b.String()
+}
+
+// words splits a name on separators and case changes, e.g. XMLHttp_request2 into XML, Http, request2
+func words(name string) []string {
+	var (
+		result []string
+		word   []rune
+	)
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				result = append(result, string(word))
+				word = nil
+			}
+			continue
+		}
+
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			// A capital starts a word after a lower case letter or a digit,
+			// or ends an acronym when followed by a lower case letter
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				result = append(result, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		result = append(result, string(word))
+	}
+	return result
+}
+
+// transformName applies the key transform to the local part of a name,
+// leaving its namespace prefix or Clark notation URI untouched
+func (t KeyTransform) transformName(name string) string {
+	var space string
+	if strings.HasPrefix(name, "{") {
+		if end := strings.Index(name, "}"); end >= 0 {
+			space, name = name[:end+1], name[end+1:]
+		}
+	} else if i := strings.LastIndex(name, ":"); i >= 0 {
+		space, name = name[:i+1], name[i+1:]
+	}
+	return space + t(name)
+}
+
+// outputKey returns the JSON key of the children stored under key, attributes keeping their prefix.
+// Renames of the child path take precedence over the key transform
+func (enc *Encoder) outputKey(key string, child *Node) string {
+	return enc.outputName(key, child.Label, child.Type)
+}
+
+// outputName returns the JSON key of a node of type t stored under key, label being its path
+func (enc *Encoder) outputName(key string, label string, t NodeType) string {
+	if name, ok := enc.renames.lookup(label); ok && label != "" {
+		return name
+	}
+	if enc.keyTransform == nil {
+		return key
+	}
+
+	switch t {
+	case ElementNode:
+		return enc.keyTransform.transformName(key)
+	case AttributeNode:
+		if !strings.HasPrefix(key, enc.attributePrefix) {
+			return key
+		}
+		return enc.attributePrefix + enc.keyTransform.transformName(key[len(enc.attributePrefix):])
+	default:
+		// Comment and processing instruction keys are set by plugins
+		return key
+	}
+}
+
+type outputPathKey struct {
+	label string
+	t     NodeType
+}
+
+// outputPath returns the dotted path of JSON keys of a node of type t, label being its path
+func (enc *Encoder) outputPath(label string, t NodeType) string {
+	if label == "" || (enc.keyTransform == nil && enc.renames.empty()) {
+		return label
+	}
+	if path, ok := enc.outputPaths[outputPathKey{label: label, t: t}]; ok {
+		return path
+	}
+
+	segments := splitPath(label)
+	keys := make([]string, len(segments))
+	for i, segment := range segments {
+		segmentType := ElementNode
+		if i == len(segments)-1 {
+			segmentType = t
+		}
+		keys[i] = enc.outputName(segment, strings.Join(segments[:i+1], pathSplitter), segmentType)
+	}
+	path := strings.Join(keys, pathSplitter)
+
+	if enc.outputPaths == nil {
+		enc.outputPaths = make(map[outputPathKey]string)
+	}
+	enc.outputPaths[outputPathKey{label: label, t: t}] = path
+	return path
+}
+
+// checkKeys returns a *KeyCollisionError if different keys of n have the same output key
+func (enc *Encoder) checkKeys(n *Node, keys []string) error {
+	if enc.keyTransform == nil && enc.renames.empty() {
+		return nil
+	}
+
+	seen := make(map[string]string, len(keys))
+	for _, key := range keys {
+		out := enc.outputKey(key, n.Children[key][0])
+		if other, ok := seen[out]; ok {
+			return &KeyCollisionError{Path: n.Label, Key: out, Names: []string{other, key}}
+		}
+		seen[out] = key
+	}
+	return nil
+}
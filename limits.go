@@ -0,0 +1,128 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Limits bounds the resources used to decode a document, a zero value meaning no limit
+type Limits struct {
+	// MaxDepth is the maximum nesting depth of elements, the document element being at depth 1
+	MaxDepth int
+	// MaxNodes is the maximum number of elements and attributes in the document
+	MaxNodes int
+	// MaxAttributes is the maximum number of attributes of an element
+	MaxAttributes int
+	// MaxTextBytes is the maximum size of a text run or an attribute value.
+	// It is checked once encoding/xml has read the whole token, only MaxInputBytes bounds the memory used
+	MaxTextBytes int
+	// MaxInputBytes is the maximum size of the document
+	MaxInputBytes int64
+}
+
+// Limit is one of the bounds of Limits
+type Limit int
+
+const (
+	LimitDepth Limit = iota
+	LimitNodes
+	LimitAttributes
+	LimitTextBytes
+	LimitInputBytes
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitDepth:
+		return "depth"
+	case LimitNodes:
+		return "nodes"
+	case LimitAttributes:
+		return "attributes"
+	case LimitTextBytes:
+		return "text bytes"
+	case LimitInputBytes:
+		return "input bytes"
+	default:
+		return fmt.Sprintf("Limit(%d)", int(l))
+	}
+}
+
+// LimitError is returned when decoding a document exceeds one of the Limits
+type LimitError struct {
+	Limit Limit
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("limit of %d %s exceeded", e.Max, e.Limit)
+}
+
+// limitReader fails with a LimitError once more than max bytes are read
+type limitReader struct {
+	reader io.Reader
+	read   int64
+	max    int64
+}
+
+func (r *limitReader) Read(p []byte) (int, error) {
+	if r.read > r.max {
+		return 0, &LimitError{Limit: LimitInputBytes, Max: r.max}
+	}
+
+	// Read a single byte past the limit to tell whether there is more
+	if remaining := r.max - r.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if r.read > r.max {
+		return n - 1, &LimitError{Limit: LimitInputBytes, Max: r.max}
+	}
+
+	return n, err
+}
+
+// limitReader returns the reader the document is read from
+func (dec *Decoder) limitReader() io.Reader {
+	if dec.limits.MaxInputBytes <= 0 {
+		return dec.reader
+	}
+	return &limitReader{reader: dec.reader, max: dec.limits.MaxInputBytes}
+}
+
+// checkElement ensures that a new element and its attributes fit in the limits
+func (dec *Decoder) checkElement(elem *element, attrs []xml.Attr) error {
+	l := dec.limits
+	if l.MaxDepth > 0 && elem.depth > l.MaxDepth {
+		return &LimitError{Limit: LimitDepth, Max: int64(l.MaxDepth)}
+	}
+	if l.MaxAttributes > 0 && len(attrs) > l.MaxAttributes {
+		return &LimitError{Limit: LimitAttributes, Max: int64(l.MaxAttributes)}
+	}
+
+	dec.nodes += 1 + len(attrs)
+	if l.MaxNodes > 0 && dec.nodes > l.MaxNodes {
+		return &LimitError{Limit: LimitNodes, Max: int64(l.MaxNodes)}
+	}
+
+	if l.MaxTextBytes > 0 {
+		for _, a := range attrs {
+			if len(a.Value) > l.MaxTextBytes {
+				return &LimitError{Limit: LimitTextBytes, Max: int64(l.MaxTextBytes)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkText ensures that a text run fits in the limits
+func (dec *Decoder) checkText(text []byte) error {
+	if dec.limits.MaxTextBytes > 0 && len(text) > dec.limits.MaxTextBytes {
+		return &LimitError{Limit: LimitTextBytes, Max: int64(dec.limits.MaxTextBytes)}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"sort"
+)
+
+const (
+	xmlnsPrefix = "xmlns"
+	xmlPrefix   = "xml"
+	xmlURL      = "http://www.w3.org/XML/1998/namespace"
+)
+
+// NamespaceMode tells how namespaces of element and attribute names are rendered
+type NamespaceMode int
+
+const (
+	// NamespaceStrip keeps only local names (default)
+	NamespaceStrip NamespaceMode = iota
+	// NamespacePrefix keeps the prefix used in the document, e.g. soap:Body
+	NamespacePrefix
+	// NamespaceMap replaces namespace URIs with user chosen prefixes,
+	// URIs without a mapping keep their document prefix
+	NamespaceMap
+	// NamespaceClark renders names in Clark notation, e.g. {http://schemas.xmlsoap.org/soap/envelope/}Body.
+	// Dotted paths do not split the namespace URIs, e.g. "{urn:a.b}doc.{urn:a.b}item"
+	NamespaceClark
+)
+
+// isDeclaration returns whether the attribute is a namespace declaration
+func isDeclaration(a xml.Attr) bool {
+	return a.Name.Space == xmlnsPrefix || (a.Name.Space == "" && a.Name.Local == xmlnsPrefix)
+}
+
+// declarations returns the namespaces declared by the attributes of an element, by prefix
+func declarations(attrs []xml.Attr) map[string]string {
+	var ns map[string]string
+	for _, a := range attrs {
+		if !isDeclaration(a) {
+			continue
+		}
+		if ns == nil {
+			ns = make(map[string]string)
+		}
+
+		if a.Name.Space == xmlnsPrefix {
+			ns[a.Name.Local] = a.Value
+		} else {
+			ns[""] = a.Value
+		}
+	}
+	return ns
+}
+
+// namespaceURI returns the namespace bound to prefix in the scope of the element
+func (elem *element) namespaceURI(prefix string) (string, bool) {
+	for e := elem; e != nil; e = e.parent {
+		if uri, ok := e.ns[prefix]; ok {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// prefix returns the prefix bound to uri in the scope of the element.
+// The default namespace wins over prefixes declared on the same element.
+func (elem *element) prefix(uri string) (string, bool) {
+	for e := elem; e != nil; e = e.parent {
+		if len(e.ns) == 0 {
+			continue
+		}
+
+		candidates := make([]string, 0, len(e.ns))
+		for p, u := range e.ns {
+			if u == uri {
+				candidates = append(candidates, p)
+			}
+		}
+		sort.Strings(candidates)
+
+		for _, p := range candidates {
+			// The prefix may be bound to another namespace deeper in the tree
+			if bound, _ := elem.namespaceURI(p); bound == uri {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+func qualify(prefix string, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+// name renders an element or attribute name according to the namespace mode
+func (dec *Decoder) name(elem *element, name xml.Name) string {
+	if name.Space == "" || dec.namespaceMode == NamespaceStrip {
+		return name.Local
+	}
+
+	if dec.namespaceMode == NamespaceClark {
+		return "{" + name.Space + "}" + name.Local
+	}
+
+	if dec.namespaceMode == NamespaceMap {
+		if p, ok := dec.namespacePrefixes[name.Space]; ok {
+			return qualify(p, name.Local)
+		}
+	}
+
+	if name.Space == xmlURL {
+		return qualify(xmlPrefix, name.Local)
+	}
+
+	if p, ok := elem.prefix(name.Space); ok {
+		return qualify(p, name.Local)
+	}
+
+	// encoding/xml leaves undeclared prefixes in place of the namespace
+	return qualify(name.Space, name.Local)
+}
+
+// attrName renders an attribute name, it returns an empty string if the attribute must be skipped
+func (dec *Decoder) attrName(elem *element, a xml.Attr) string {
+	if dec.namespaceMode == NamespaceStrip {
+		return a.Name.Local
+	}
+
+	if !isDeclaration(a) {
+		return dec.name(elem, a.Name)
+	}
+
+	if !dec.namespaceDeclarations {
+		return ""
+	}
+	if a.Name.Space != xmlnsPrefix {
+		return xmlnsPrefix
+	}
+
+	prefix := a.Name.Local
+	if dec.namespaceMode == NamespaceMap {
+		if p, ok := dec.namespacePrefixes[a.Value]; ok {
+			prefix = p
+		}
+	}
+	return qualify(xmlnsPrefix, prefix)
+}
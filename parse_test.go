@@ -110,3 +110,32 @@ func (t *TestParse) TestMixedParsing() {
 	t.Equal("true", product.Deleted[0], "deleted should match")
 	t.Equal("null", product.Nullable[0], "nullable should match")
 }
+
+func (t *TestParse) TestTypeHints() {
+	s := `<orders>
+		<order id="0042"><zip>01234</zip><sku>1234</sku><qty>3</qty><price cur="EUR">12.50</price><gift/></order>
+		<order id="43"><zip>75001</zip><sku>A-12</sku><qty>1</qty><price cur="USD">7</price><gift>true</gift></order>
+	</orders>`
+
+	converter := xml2json.NewConverter(
+		xml2json.WithAttrPrefix("-"),
+		xml2json.WithTypeConverter(xml2json.Int, xml2json.Float, xml2json.Bool),
+		xml2json.WithTypeHints(map[string]xml2json.JSType{
+			"orders.order.zip":  xml2json.String,
+			"orders.*.sku":      xml2json.String,
+			"**.-id":            xml2json.Int,
+			"**.price":          xml2json.Float,
+			"orders.order.gift": xml2json.Bool,
+		}),
+	)
+
+	actual, err := converter.Convert(strings.NewReader(s))
+	t.NoError(err)
+	t.JSONEq(`{"orders": {"order": [
+		{"-id": 42, "zip": "01234", "sku": "1234", "qty": 3, "price": {"-cur": "EUR", "content": 12.5}, "gift": null},
+		{"-id": 43, "zip": "75001", "sku": "A-12", "qty": 1, "price": {"-cur": "USD", "content": 7}, "gift": true}
+	]}}`, actual.String())
+
+	_, err = converter.Convert(strings.NewReader(`<orders><order id="x"/></orders>`))
+	t.Error(err)
+}
@@ -0,0 +1,163 @@
+package xml2json
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	anySegment   = "*"
+	anySegments  = "**"
+	pathSplitter = "."
+)
+
+// pathPattern is a dotted path, like Node.Label, where a "*" segment matches any single segment
+// and a "**" segment matches any number of segments, including none
+type pathPattern []string
+
+func compilePath(path string) pathPattern {
+	return splitPath(path)
+}
+
+// splitPath returns the segments of a dotted path. The dots of the namespace URIs of names
+// in Clark notation, e.g. {http://schemas.xmlsoap.org/soap/envelope/}Body, do not split it
+func splitPath(path string) []string {
+	if !strings.Contains(path, "{") {
+		return strings.Split(path, pathSplitter)
+	}
+
+	var (
+		segments []string
+		start    int
+		inURI    bool
+	)
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '{':
+			inURI = true
+		case path[i] == '}':
+			inURI = false
+		case !inURI && path[i] == pathSplitter[0]:
+			segments = append(segments, path[start:i])
+			start = i + 1
+		}
+	}
+	return append(segments, path[start:])
+}
+
+func (p pathPattern) isLiteral() bool {
+	return p.literals() == len(p)
+}
+
+// literals returns the number of segments which are not wildcards
+func (p pathPattern) literals() int {
+	count := 0
+	for _, s := range p {
+		if s != anySegment && s != anySegments {
+			count++
+		}
+	}
+	return count
+}
+
+func (p pathPattern) match(path string) bool {
+	return matchSegments(p, splitPath(path))
+}
+
+// matchAncestor returns whether the pattern may match a descendant of path
+func (p pathPattern) matchAncestor(path string) bool {
+	return matchAncestorSegments(p, splitPath(path))
+}
+
+func matchAncestorSegments(pattern []string, segments []string) bool {
+	for len(segments) > 0 {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == anySegments {
+			// The descendant segments can always be matched by "**"
+			return true
+		}
+		if pattern[0] != anySegment && pattern[0] != segments[0] {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(pattern) > 0
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == anySegments {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 || (pattern[0] != anySegment && pattern[0] != segments[0]) {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// pathMap maps paths and path patterns to values. Exact paths win over patterns,
+// then patterns with more literal segments win
+type pathMap[T any] struct {
+	exact    map[string]T
+	patterns []pathEntry[T]
+}
+
+type pathEntry[T any] struct {
+	path    string
+	pattern pathPattern
+	value   T
+}
+
+func (m *pathMap[T]) set(path string, value T) {
+	pattern := compilePath(path)
+	if pattern.isLiteral() {
+		if m.exact == nil {
+			m.exact = make(map[string]T)
+		}
+		m.exact[path] = value
+		return
+	}
+
+	for i, e := range m.patterns {
+		if e.path == path {
+			m.patterns[i].value = value
+			return
+		}
+	}
+	m.patterns = append(m.patterns, pathEntry[T]{path: path, pattern: pattern, value: value})
+	sort.SliceStable(m.patterns, func(i, j int) bool {
+		li, lj := m.patterns[i].pattern.literals(), m.patterns[j].pattern.literals()
+		if li != lj {
+			return li > lj
+		}
+		return m.patterns[i].path < m.patterns[j].path
+	})
+}
+
+func (m *pathMap[T]) lookup(path string) (T, bool) {
+	if v, ok := m.exact[path]; ok {
+		return v, true
+	}
+	for _, e := range m.patterns {
+		if e.pattern.match(path) {
+			return e.value, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+func (m *pathMap[T]) empty() bool {
+	return len(m.exact) == 0 && len(m.patterns) == 0
+}
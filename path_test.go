@@ -0,0 +1,88 @@
+package xml2json
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathPatternMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	table := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{pattern: "osm.node", path: "osm.node", expected: true},
+		{pattern: "osm.node", path: "osm.node.tag", expected: false},
+		{pattern: "osm.*", path: "osm.node", expected: true},
+		{pattern: "osm.*", path: "osm", expected: false},
+		{pattern: "osm.*.-k", path: "osm.node.tag.-k", expected: false},
+		{pattern: "osm.**.-k", path: "osm.node.tag.-k", expected: true},
+		{pattern: "osm.**.-k", path: "osm.-k", expected: true},
+		{pattern: "**.Signature", path: "Envelope.Header.Security.Signature", expected: true},
+		{pattern: "**.Signature", path: "Signature", expected: true},
+		{pattern: "**", path: "a.b.c", expected: true},
+		{pattern: "a.**.c.*", path: "a.b.c.c.d", expected: true},
+		{pattern: "a.**.c.*", path: "a.b.c", expected: false},
+		{pattern: "{urn:a.b}doc.*", path: "{urn:a.b}doc.{urn:a.b}item", expected: true},
+		{pattern: "**.-{urn:a.b}id", path: "{urn:a.b}doc.-{urn:a.b}id", expected: true},
+		{pattern: "{urn:a.b}doc.*", path: "{urn:a.b}doc", expected: false},
+	}
+
+	for _, scenario := range table {
+		assert.Equal(scenario.expected, compilePath(scenario.pattern).match(scenario.path), "%s ~ %s", scenario.pattern, scenario.path)
+	}
+}
+
+func TestPathPatternMatchAncestor(t *testing.T) {
+	assert := assert.New(t)
+
+	table := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{pattern: "osm.node.tag", path: "osm", expected: true},
+		{pattern: "osm.node.tag", path: "osm.node", expected: true},
+		{pattern: "osm.node.tag", path: "osm.node.tag", expected: false},
+		{pattern: "osm.node.tag", path: "osm.way", expected: false},
+		{pattern: "osm.*.tag", path: "osm.way", expected: true},
+		{pattern: "**.Signature", path: "Envelope.Header", expected: true},
+		{pattern: "a.**", path: "b", expected: false},
+		{pattern: "a.**", path: "a", expected: true},
+	}
+
+	for _, scenario := range table {
+		assert.Equal(scenario.expected, compilePath(scenario.pattern).matchAncestor(scenario.path), "%s ~ %s", scenario.pattern, scenario.path)
+	}
+}
+
+func TestPathMapLookup(t *testing.T) {
+	assert := assert.New(t)
+
+	m := pathMap[int]{}
+	assert.True(m.empty())
+
+	m.set("**.id", 1)
+	m.set("osm.*.id", 2)
+	m.set("osm.node.id", 3)
+	m.set("**.id", 4)
+	assert.False(m.empty())
+
+	v, ok := m.lookup("osm.node.id")
+	assert.True(ok)
+	assert.Equal(3, v)
+
+	v, ok = m.lookup("osm.way.id")
+	assert.True(ok)
+	assert.Equal(2, v)
+
+	v, ok = m.lookup("id")
+	assert.True(ok)
+	assert.Equal(4, v)
+
+	_, ok = m.lookup("osm.node.ref")
+	assert.False(ok)
+}
@@ -1,6 +1,7 @@
 package xml2json
 
 import (
+	"reflect"
 	"strings"
 )
 
@@ -97,6 +98,23 @@ func (c *contentPrefixer) AddToDecoder(d *Decoder) *Decoder {
 	return d
 }
 
+type contentKey string
+
+// WithContentKey sets the full key of the text of complex nodes, e.g. "#text", "$t" or "value",
+// instead of the content prefix followed by "content"
+func WithContentKey(key string) Plugin {
+	return contentKey(key)
+}
+
+func (c contentKey) AddToEncoder(e *Encoder) *Encoder {
+	e.contentKeyName = string(c)
+	return e
+}
+
+func (c contentKey) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
 type excluder []string
 
 // ExcludeAttributes excludes some xml attributes, for example, xmlns:xsi, xsi:noNamespaceSchemaLocation
@@ -133,6 +151,8 @@ type attrToArray struct {
 	attrList []string
 }
 
+// AttrToArray always encodes the children found at the given dotted paths as arrays,
+// in addition to the paths set by other plugins, see FromStruct
 func AttrToArray(attrList ...string) Plugin {
 	return attrToArray{
 		attrList: attrList,
@@ -140,15 +160,466 @@ func AttrToArray(attrList ...string) Plugin {
 }
 
 func (p attrToArray) AddToEncoder(e *Encoder) *Encoder {
-	attrIsArrayMap := make(map[string]bool)
+	if e.attrIsAlwaysAnArray == nil {
+		e.attrIsAlwaysAnArray = make(map[string]bool)
+	}
 	for _, s := range p.attrList {
-		attrIsArrayMap[s] = true
+		e.attrIsAlwaysAnArray[s] = true
 	}
-
-	e.attrIsAlwaysAnArray = attrIsArrayMap
 	return e
 }
 
 func (p attrToArray) AddToDecoder(d *Decoder) *Decoder {
 	return d
 }
+
+// MixedContentMode tells how text interleaved with child elements is decoded and encoded
+type MixedContentMode int
+
+const (
+	// MixedContentNone keeps only the last text run of an element, e.g.
+	// <p>Hello <b>big</b> world</p> gives "world"
+	MixedContentNone MixedContentMode = iota
+	// MixedContentConcat concatenates all the text runs of an element as they appear in the document,
+	// the whitespace mode applying to the whole text, e.g. <p>Hello <b>big</b> world</p> gives
+	// {"b": "big", "content": "Hello  world"} and <p>foo<b>x</b>bar</p> gives {"b": "x", "content": "foobar"}
+	MixedContentConcat
+	// MixedContentParts keeps text runs and child elements in document order, e.g.
+	// <p>Hello <b>big</b> world</p> gives {"content": ["Hello", {"b": "big"}, "world"]}.
+	// Attributes and elements without text around them are encoded as usual
+	MixedContentParts
+)
+
+type mixedContent MixedContentMode
+
+// WithMixedContent sets how text interleaved with child elements is kept
+func WithMixedContent(mode MixedContentMode) Plugin {
+	return mixedContent(mode)
+}
+
+func (m mixedContent) AddToEncoder(e *Encoder) *Encoder {
+	e.mixedContent = MixedContentMode(m)
+	return e
+}
+
+func (m mixedContent) AddToDecoder(d *Decoder) *Decoder {
+	d.mixedContent = MixedContentMode(m)
+	return d
+}
+
+type namespaces NamespaceMode
+
+// WithNamespaces sets how namespaces of element and attribute names are rendered
+func WithNamespaces(mode NamespaceMode) Plugin {
+	return namespaces(mode)
+}
+
+func (ns namespaces) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (ns namespaces) AddToDecoder(d *Decoder) *Decoder {
+	d.namespaceMode = NamespaceMode(ns)
+	return d
+}
+
+type namespacePrefixes map[string]string
+
+// WithNamespacePrefixes renders namespaces with the given prefixes, keyed by namespace URI.
+// It implies the NamespaceMap mode
+func WithNamespacePrefixes(prefixes map[string]string) Plugin {
+	return namespacePrefixes(prefixes)
+}
+
+func (ns namespacePrefixes) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (ns namespacePrefixes) AddToDecoder(d *Decoder) *Decoder {
+	d.namespaceMode = NamespaceMap
+	d.namespacePrefixes = ns
+	return d
+}
+
+type namespaceDeclarations struct{}
+
+// WithNamespaceDeclarations keeps xmlns declarations as attributes, e.g. xmlns:soap.
+// With NamespaceStrip declarations are always kept under their local name
+func WithNamespaceDeclarations() Plugin {
+	return namespaceDeclarations{}
+}
+
+func (ns namespaceDeclarations) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (ns namespaceDeclarations) AddToDecoder(d *Decoder) *Decoder {
+	d.namespaceDeclarations = true
+	return d
+}
+
+type indenter struct {
+	prefix string
+	indent string
+}
+
+// WithIndent formats the json output with the given prefix and indentation, see Encoder.SetIndent
+func WithIndent(prefix string, indent string) Plugin {
+	return indenter{
+		prefix: prefix,
+		indent: indent,
+	}
+}
+
+func (i indenter) AddToEncoder(e *Encoder) *Encoder {
+	e.SetIndent(i.prefix, i.indent)
+	return e
+}
+
+func (i indenter) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
+type compacter struct{}
+
+// WithCompact leaves out the spaces after commas and colons in the json output
+func WithCompact() Plugin {
+	return compacter{}
+}
+
+func (c compacter) AddToEncoder(e *Encoder) *Encoder {
+	e.SetCompact(true)
+	return e
+}
+
+func (c compacter) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
+type typeHints map[string]JSType
+
+// WithTypeHints sets the JSType of the values found at the given dotted paths, e.g. "order.zip" or "**.-id",
+// see Node.Label. A "*" segment matches any single segment and a "**" segment any number of segments.
+// Values at other paths are converted as usual, i.e. guessed with WithTypeConverter or kept as strings.
+// Empty values are encoded as null unless their type is String, and values which cannot be
+// converted to their type make the encoding fail
+func WithTypeHints(hints map[string]JSType) Plugin {
+	return typeHints(hints)
+}
+
+func (h typeHints) AddToEncoder(e *Encoder) *Encoder {
+	for path, t := range h {
+		e.typeHints.set(path, t)
+	}
+	return e
+}
+
+func (h typeHints) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
+type limiter Limits
+
+// WithLimits bounds the resources used to decode a document,
+// decoding fails with a *LimitError as soon as one of the limits is exceeded
+func WithLimits(limits Limits) Plugin {
+	return limiter(limits)
+}
+
+func (l limiter) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (l limiter) AddToDecoder(d *Decoder) *Decoder {
+	d.limits = Limits(l)
+	return d
+}
+
+type cdata string
+
+// WithCDATA keeps the content of CDATA sections byte for byte instead of trimming it like text.
+// If key is not empty, CDATA content is written under this key, e.g. {"script": {"#cdata": "..."}},
+// so that the reverse conversion restores the sections.
+// CDATA sections are only told apart from text in UTF-8 documents
+func WithCDATA(key string) Plugin {
+	return cdata(key)
+}
+
+func (c cdata) AddToEncoder(e *Encoder) *Encoder {
+	e.cdataKey = string(c)
+	return e
+}
+
+func (c cdata) AddToDecoder(d *Decoder) *Decoder {
+	d.cdata = true
+	return d
+}
+
+type comments string
+
+// WithComments keeps comments under the given key of their enclosing element, e.g. {"#comment": " generated "}.
+// The comments of an element are grouped into an array at the position of the first one, so only
+// mixed content decoded with MixedContentParts keeps every comment in document position. An empty key drops comments
+func WithComments(key string) Plugin {
+	return comments(key)
+}
+
+func (c comments) AddToEncoder(e *Encoder) *Encoder {
+	e.commentKey = string(c)
+	return e
+}
+
+func (c comments) AddToDecoder(d *Decoder) *Decoder {
+	d.commentKey = string(c)
+	return d
+}
+
+type procInsts string
+
+// WithProcInsts keeps processing instructions under their target prepended with the given prefix,
+// e.g. {"?xml-stylesheet": "href=\"style.xsl\""}, grouped by target like comments, see WithComments.
+// The XML declaration is never kept. An empty prefix drops processing instructions
+func WithProcInsts(prefix string) Plugin {
+	return procInsts(prefix)
+}
+
+func (p procInsts) AddToEncoder(e *Encoder) *Encoder {
+	e.procInstPrefix = string(p)
+	return e
+}
+
+func (p procInsts) AddToDecoder(d *Decoder) *Decoder {
+	d.procInstPrefix = string(p)
+	return d
+}
+
+type whitespace struct {
+	mode  WhitespaceMode
+	paths []string
+}
+
+// WithWhitespace sets how the whitespace of text is handled, for the whole document
+// or for the elements found at the given dotted paths, e.g. "poem.verse" or "**.code".
+// Elements with xml:space="preserve", and their descendants, keep their whitespace
+// unless a mode is set for their path, see WithoutXMLSpace
+func WithWhitespace(mode WhitespaceMode, paths ...string) Plugin {
+	return whitespace{
+		mode:  mode,
+		paths: paths,
+	}
+}
+
+func (w whitespace) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (w whitespace) AddToDecoder(d *Decoder) *Decoder {
+	if len(w.paths) == 0 {
+		d.whitespace = w.mode
+	}
+	for _, path := range w.paths {
+		d.whitespacePaths.set(path, w.mode)
+	}
+	return d
+}
+
+type withoutXMLSpace struct{}
+
+// WithoutXMLSpace ignores xml:space attributes when handling whitespace
+func WithoutXMLSpace() Plugin {
+	return withoutXMLSpace{}
+}
+
+func (w withoutXMLSpace) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (w withoutXMLSpace) AddToDecoder(d *Decoder) *Decoder {
+	d.ignoreXMLSpace = true
+	return d
+}
+
+type collisionPolicy CollisionPolicy
+
+// WithCollisionPolicy sets what to do when an attribute and a child element share the same key
+func WithCollisionPolicy(policy CollisionPolicy) Plugin {
+	return collisionPolicy(policy)
+}
+
+func (p collisionPolicy) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (p collisionPolicy) AddToDecoder(d *Decoder) *Decoder {
+	d.collisionPolicy = CollisionPolicy(p)
+	return d
+}
+
+type collisionReporter func(Collision)
+
+// OnCollision calls fn for every attribute and child element sharing the same key,
+// before the collision policy is applied
+func OnCollision(fn func(Collision)) Plugin {
+	return collisionReporter(fn)
+}
+
+func (r collisionReporter) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (r collisionReporter) AddToDecoder(d *Decoder) *Decoder {
+	d.onCollision = r
+	return d
+}
+
+type convention Convention
+
+// WithConvention sets the prefixes, keys and rendering of a well-known convention.
+// Plugins given after it can still change its settings
+func WithConvention(c Convention) Plugin {
+	return convention(c)
+}
+
+func (c convention) AddToEncoder(e *Encoder) *Encoder {
+	switch Convention(c) {
+	case BadgerFish:
+		e.attributePrefix = badgerFishAttrPrefix
+		e.contentKeyName = badgerFishText
+		e.alwaysObject = true
+	case Parker:
+		e.stripRoot = true
+		e.dropAttributes = true
+		e.dropMixedText = true
+		e.emptyAsNull = true
+		e.tc = &customTypeConverter{parseTypes: []JSType{Bool, Int, Float}}
+	case JsonML:
+		e.jsonML = true
+	}
+	return e
+}
+
+func (c convention) AddToDecoder(d *Decoder) *Decoder {
+	switch Convention(c) {
+	case BadgerFish:
+		d.attributePrefix = badgerFishAttrPrefix
+		d.namespaceMode = NamespacePrefix
+		d.namespaceDeclarations = false
+		d.namespaceScope = true
+	case Parker:
+		d.dropAttributes = true
+	case JsonML:
+		d.mixedContent = MixedContentParts
+		d.whitespace = WhitespacePreserve
+	}
+	return d
+}
+
+type keyTransformer KeyTransform
+
+// WithKeyTransform rewrites the names of elements and attributes in the json keys with a built-in
+// strategy, e.g. CamelCase, or any func(string) string. The attribute and content prefixes are kept
+// as is and only the local part of namespaced names is rewritten.
+// Encoding fails with a *KeyCollisionError when two names of an element get the same key
+func WithKeyTransform(transform KeyTransform) Plugin {
+	return keyTransformer(transform)
+}
+
+func (t keyTransformer) AddToEncoder(e *Encoder) *Encoder {
+	e.keyTransform = KeyTransform(t)
+	return e
+}
+
+func (t keyTransformer) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
+type renames map[string]string
+
+// WithRenames sets the json keys of the elements and attributes found at the given dotted paths,
+// e.g. "Envelope.Body.GetPriceResponse.Price" or "**.-cur", see WithTypeHints for the path syntax.
+// The new keys are written as is, the attribute prefix and WithKeyTransform do not apply to them
+func WithRenames(keys map[string]string) Plugin {
+	return renames(keys)
+}
+
+func (r renames) AddToEncoder(e *Encoder) *Encoder {
+	for path, key := range r {
+		e.renames.set(path, key)
+	}
+	return e
+}
+
+func (r renames) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
+type pathIncluder []string
+
+// IncludePaths keeps only the elements and attributes found at the given dotted paths, e.g. "order.lines"
+// or "**.-id", see WithTypeHints for the path syntax. Included elements are kept with their whole subtree,
+// and their ancestors without their own text and attributes. Everything else is skipped while decoding
+func IncludePaths(paths ...string) Plugin {
+	return pathIncluder(paths)
+}
+
+func (p pathIncluder) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (p pathIncluder) AddToDecoder(d *Decoder) *Decoder {
+	for _, path := range p {
+		d.includePaths = append(d.includePaths, compilePath(path))
+	}
+	return d
+}
+
+type pathExcluder []string
+
+// ExcludePaths drops the elements, with their whole subtree, and the attributes found at the given dotted paths,
+// e.g. "Envelope.Header" or "**.Signature", see WithTypeHints for the path syntax.
+// Excluded subtrees are skipped while decoding and never kept in memory, though still checked against WithLimits.
+// Exclusions win over IncludePaths
+func ExcludePaths(paths ...string) Plugin {
+	return pathExcluder(paths)
+}
+
+func (p pathExcluder) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (p pathExcluder) AddToDecoder(d *Decoder) *Decoder {
+	for _, path := range p {
+		d.excludePaths = append(d.excludePaths, compilePath(path))
+	}
+	return d
+}
+
+type structHints structType
+
+// FromStruct configures the encoder after the json tags of T, the type documents are unmarshalled into,
+// see Node.Unmarshal: slice and array fields are always encoded as arrays, see AttrToArray,
+// and numeric, bool and string fields get type hints, see WithTypeHints, so strings are never type-guessed.
+// Fields are matched with the json keys, after WithKeyTransform and WithRenames,
+// the field named after the content key holding the text of its struct
+func FromStruct[T any]() Plugin {
+	return FromStructAt[T]("")
+}
+
+// FromStructAt is like FromStruct for a type mapped onto the nodes found at the given dotted path, see Node.Label,
+// e.g. the records of a RecordConverter
+func FromStructAt[T any](path string) Plugin {
+	return structHints{
+		path: path,
+		typ:  reflect.TypeOf((*T)(nil)).Elem(),
+	}
+}
+
+func (h structHints) AddToEncoder(e *Encoder) *Encoder {
+	// The hints are set on the first encoding, once the keys are known
+	e.structTypes = append(e.structTypes, structType(h))
+	return e
+}
+
+func (h structHints) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
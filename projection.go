@@ -0,0 +1,94 @@
+package xml2json
+
+import (
+	"encoding/xml"
+
+	"github.com/pkg/errors"
+)
+
+// projected tells whether the decoder keeps only some paths of the document
+func (dec *Decoder) projected() bool {
+	return len(dec.includePaths) > 0
+}
+
+// excluded returns whether the element or attribute at path is dropped by ExcludePaths
+func (dec *Decoder) excluded(path string) bool {
+	for _, p := range dec.excludePaths {
+		if p.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// included returns whether the element or attribute at path is kept by IncludePaths
+func (dec *Decoder) included(path string) bool {
+	for _, p := range dec.includePaths {
+		if p.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// includesDescendant returns whether IncludePaths may keep a descendant of the element at path
+func (dec *Decoder) includesDescendant(path string) bool {
+	for _, p := range dec.includePaths {
+		if p.matchAncestor(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipElement returns whether the subtree of the element is dropped by the path projection,
+// or otherwise marks the element as partial when only some of its descendants are kept
+func (dec *Decoder) skipElement(elem *element) bool {
+	if dec.excluded(elem.path) {
+		return true
+	}
+	if !elem.parent.partial {
+		return false
+	}
+
+	if dec.included(elem.path) {
+		return false
+	}
+	elem.partial = true
+	return !dec.includesDescendant(elem.path)
+}
+
+// skipAttribute returns whether the attribute stored under key is dropped by the path projection
+func (dec *Decoder) skipAttribute(elem *element, key string) bool {
+	path := joinPath(elem.path, key)
+	return dec.excluded(path) || (elem.partial && !dec.included(path))
+}
+
+// skip reads the subtree of an excluded element without decoding it nor recording its raw bytes.
+// The limits still apply, as encoding/xml keeps track of the open elements
+func (dec *Decoder) skip(xmlDec *xml.Decoder, recorder *rawRecorder, elem *element) error {
+	for depth := elem.depth; depth >= elem.depth; {
+		t, err := xmlDec.Token()
+		if err != nil {
+			return errors.WithMessage(err, "xml decoder token")
+		}
+
+		switch se := t.(type) {
+		case xml.StartElement:
+			depth++
+			err = dec.checkElement(&element{depth: depth}, se.Attr)
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			err = dec.checkText(se)
+		}
+		if err != nil {
+			return err
+		}
+
+		if recorder != nil {
+			recorder.forget(xmlDec.InputOffset())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package xml2json
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// queryStep selects children of the current nodes by key, then filters them
+type queryStep struct {
+	key     string
+	filters []queryFilter
+}
+
+// queryFilter keeps the candidate at index, or the candidates having a child at key,
+// holding value if set
+type queryFilter struct {
+	index    int
+	key      string
+	value    string
+	hasValue bool
+}
+
+// Query returns the descendants of the node matching a dotted path relative to it, like GetChild.
+// The children of a node are returned by key, in the order of ChildKeys, so "*" on <r><a/><b/><a/></r>
+// gives both a before b. Segments are child keys, "*" for any single key or "**" for any number of levels.
+// A segment may be followed by filters, applied in order among the children of each parent:
+// a 0-based index, e.g. "osm.node[2]", or a predicate on a child of the candidates,
+// usually an attribute, e.g. "osm.node.tag[-k=name]" or "osm.node[-visible]". Values may be quoted.
+func (n *Node) Query(expr string) (Nodes, error) {
+	steps, err := parseQuery(expr)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "parse query %q", expr)
+	}
+
+	nodes := Nodes{n}
+	for _, step := range steps {
+		if step.key == anySegments {
+			nodes = descendantsOrSelf(nodes)
+			continue
+		}
+
+		var result Nodes
+		for _, parent := range nodes {
+			result = append(result, step.apply(parent)...)
+		}
+		nodes = result
+	}
+
+	return nodes, nil
+}
+
+func (s queryStep) apply(parent *Node) Nodes {
+	var candidates Nodes
+	if s.key == anySegment {
+		for _, key := range parent.ChildKeys() {
+			candidates = append(candidates, parent.Children[key]...)
+		}
+	} else {
+		candidates = parent.Children[s.key]
+	}
+
+	for _, f := range s.filters {
+		if f.key == "" {
+			if f.index >= len(candidates) {
+				return nil
+			}
+			candidates = Nodes{candidates[f.index]}
+			continue
+		}
+
+		var kept Nodes
+		for _, c := range candidates {
+			if f.match(c) {
+				kept = append(kept, c)
+			}
+		}
+		candidates = kept
+	}
+
+	return candidates
+}
+
+func (f queryFilter) match(n *Node) bool {
+	children, exists := n.Children[f.key]
+	if !exists || !f.hasValue {
+		return exists
+	}
+	for _, c := range children {
+		if c.Data == f.value {
+			return true
+		}
+	}
+	return false
+}
+
+// descendantsOrSelf returns the nodes followed by their descendants, each node once
+func descendantsOrSelf(nodes Nodes) Nodes {
+	var (
+		result Nodes
+		seen   = make(map[*Node]bool)
+		visit  func(n *Node)
+	)
+	visit = func(n *Node) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		result = append(result, n)
+		for _, key := range n.ChildKeys() {
+			for _, c := range n.Children[key] {
+				visit(c)
+			}
+		}
+	}
+
+	for _, n := range nodes {
+		visit(n)
+	}
+	return result
+}
+
+// parseQuery splits a query into steps, dots within filters and namespace URIs being part of them
+func parseQuery(expr string) ([]queryStep, error) {
+	if expr == "" {
+		return nil, errors.New("empty query")
+	}
+
+	var (
+		steps []queryStep
+		step  queryStep
+		key   strings.Builder
+	)
+	for i := 0; i <= len(expr); i++ {
+		if i == len(expr) || expr[i] == '.' {
+			step.key = key.String()
+			if step.key == "" {
+				return nil, errors.Errorf("empty segment at %d", i)
+			}
+			if step.key == anySegments && len(step.filters) > 0 {
+				return nil, errors.Errorf("filters cannot apply to %s", anySegments)
+			}
+			steps = append(steps, step)
+			step = queryStep{}
+			key.Reset()
+			continue
+		}
+
+		if expr[i] != '[' {
+			if len(step.filters) > 0 {
+				return nil, errors.Errorf("unexpected %q after filter at %d", expr[i], i)
+			}
+			if expr[i] == '{' {
+				// The namespace URI of a name in Clark notation is part of the key
+				end := strings.IndexByte(expr[i:], '}')
+				if end < 0 {
+					return nil, errors.Errorf("unclosed namespace at %d", i)
+				}
+				key.WriteString(expr[i : i+end])
+				i += end
+			}
+			key.WriteByte(expr[i])
+			continue
+		}
+
+		end := filterEnd(expr, i+1)
+		if end < 0 {
+			return nil, errors.Errorf("unclosed filter at %d", i)
+		}
+		f, err := parseFilter(expr[i+1 : end])
+		if err != nil {
+			return nil, errors.WithMessagef(err, "filter at %d", i)
+		}
+		step.filters = append(step.filters, f)
+		i = end
+	}
+
+	return steps, nil
+}
+
+// filterEnd returns the index of the bracket closing a filter starting at i, skipping quoted values
+func filterEnd(expr string, i int) int {
+	var quote byte
+	for ; i < len(expr); i++ {
+		switch {
+		case quote != 0:
+			if expr[i] == quote {
+				quote = 0
+			}
+		case expr[i] == '\'' || expr[i] == '"':
+			quote = expr[i]
+		case expr[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseFilter(s string) (queryFilter, error) {
+	if s == "" {
+		return queryFilter{}, errors.New("empty filter")
+	}
+	if index, err := strconv.Atoi(s); err == nil {
+		if index < 0 {
+			return queryFilter{}, errors.Errorf("negative index %d", index)
+		}
+		return queryFilter{index: index}, nil
+	}
+
+	key, value, hasValue := strings.Cut(s, "=")
+	if key == "" {
+		return queryFilter{}, errors.Errorf("empty key in %q", s)
+	}
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return queryFilter{key: key, value: value, hasValue: hasValue}, nil
+}
@@ -1,7 +1,19 @@
 package xml2json
 
 import (
-	"strings"
+	"sort"
+)
+
+// NodeType tells which XML construct a node was decoded from
+type NodeType int
+
+const (
+	ElementNode NodeType = iota
+	AttributeNode
+	// CommentNode holds the text of a comment, see WithComments
+	CommentNode
+	// ProcInstNode holds the instruction of a processing instruction, see WithProcInsts
+	ProcInstNode
 )
 
 // Node is a data element on a tree
@@ -9,11 +21,36 @@ type Node struct {
 	Label    string
 	Children map[string]Nodes
 	Data     string
+	Type     NodeType
+	// CDATA tells that Data was read from a CDATA section, see WithCDATA
+	CDATA bool
+
+	// Segments holds text and child elements in document order.
+	// It is only filled when decoding with MixedContentParts.
+	Segments []Segment
+
+	// keys holds the children keys in the order they were first added
+	keys []string
+	// listed holds the keys found in keys, so that adding a child does not scan them
+	listed map[string]bool
 }
 
 // Nodes is a list of nodes
 type Nodes []*Node
 
+// Segment is a piece of the content of a node: either a text run or a child node
+type Segment struct {
+	Text  string
+	CDATA bool
+	Label string
+	Node  *Node
+}
+
+// IsText returns whether the segment is a text run
+func (s Segment) IsText() bool {
+	return s.Node == nil
+}
+
 // AddChild appends a node to the list of children
 func (n *Node) AddChild(s string, c *Node) {
 	// Lazy lazy
@@ -21,18 +58,104 @@ func (n *Node) AddChild(s string, c *Node) {
 		n.Children = map[string]Nodes{}
 	}
 
+	if _, exists := n.Children[s]; !exists {
+		// The key may be left over from a child removed from the map
+		n.dropKey(s)
+		n.appendKey(s)
+	}
 	n.Children[s] = append(n.Children[s], c)
 }
 
+// ChildKeys returns the keys of the children in the order they were first added.
+// Keys set directly on the Children map without AddChild come last, sorted.
+func (n *Node) ChildKeys() []string {
+	keys := make([]string, 0, len(n.Children))
+	seen := make(map[string]bool, len(n.Children))
+	for _, k := range n.keys {
+		if _, exists := n.Children[k]; exists && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) < len(n.Children) {
+		extra := make([]string, 0, len(n.Children)-len(keys))
+		for k := range n.Children {
+			if !seen[k] {
+				extra = append(extra, k)
+			}
+		}
+		sort.Strings(extra)
+		keys = append(keys, extra...)
+	}
+
+	return keys
+}
+
+// appendKey lists a key which is not in keys yet
+func (n *Node) appendKey(key string) {
+	if n.listed == nil {
+		n.listed = map[string]bool{}
+	}
+	n.listed[key] = true
+	n.keys = append(n.keys, key)
+}
+
+// dropKey removes a key from keys, if listed
+func (n *Node) dropKey(key string) {
+	if !n.listed[key] {
+		return
+	}
+	delete(n.listed, key)
+	n.keys = removeKey(n.keys, key)
+}
+
+func removeKey(keys []string, key string) []string {
+	result := keys[:0]
+	for _, k := range keys {
+		if k != key {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// setLabels sets the dotted path of the node and of all its descendants
+func (n *Node) setLabels(path string) {
+	n.Label = path
+	for label, nodes := range n.Children {
+		childPath := joinPath(path, label)
+		for _, c := range nodes {
+			c.setLabels(childPath)
+		}
+	}
+}
+
 // IsComplex returns whether it is a complex type (has children)
 func (n *Node) IsComplex() bool {
 	return len(n.Children) > 0
 }
 
+// IsMixed returns whether text segments are interleaved with child elements or CDATA sections
+func (n *Node) IsMixed() bool {
+	hasText, hasCDATA, hasElement := false, false, false
+	for _, s := range n.Segments {
+		switch {
+		case !s.IsText():
+			hasElement = true
+		case s.CDATA:
+			hasCDATA = true
+		default:
+			hasText = true
+		}
+	}
+	return (hasText || hasCDATA) && hasElement || hasText && hasCDATA
+}
+
 // GetChild returns child by path if exists. Path looks like "grandparent.parent.child.grandchild"
 func (n *Node) GetChild(path string) *Node {
 	result := n
-	names := strings.Split(path, ".")
+	names := splitPath(path)
 	for _, name := range names {
 		children, exists := result.Children[name]
 		if !exists {
@@ -45,3 +168,149 @@ func (n *Node) GetChild(path string) *Node {
 	}
 	return result
 }
+
+// childIndex returns the index of c among the children stored under key, or -1
+func (n *Node) childIndex(key string, c *Node) int {
+	for i, child := range n.Children[key] {
+		if child == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func (n *Node) hasChild(key string, c *Node) bool {
+	return n.childIndex(key, c) >= 0
+}
+
+// RemoveChild removes the child c stored under key, and returns whether it was found
+func (n *Node) RemoveChild(key string, c *Node) bool {
+	i := n.childIndex(key, c)
+	if i < 0 {
+		return false
+	}
+
+	children := n.Children[key]
+	if len(children) == 1 {
+		delete(n.Children, key)
+		n.dropKey(key)
+	} else {
+		n.Children[key] = append(children[:i:i], children[i+1:]...)
+	}
+	n.Segments = removeSegments(n.Segments, Nodes{c})
+
+	return true
+}
+
+// ReplaceChild puts c in place of the child old stored under key, and returns whether old was found.
+// Nothing is replaced if c is nil, use RemoveChild instead.
+// The labels of c and its descendants are set from the label of the node
+func (n *Node) ReplaceChild(key string, old *Node, c *Node) bool {
+	i := n.childIndex(key, old)
+	if i < 0 || c == nil {
+		return false
+	}
+
+	n.Children[key][i] = c
+	for j, s := range n.Segments {
+		if s.Node == old {
+			n.Segments[j].Node = c
+		}
+	}
+	c.setLabels(joinPath(n.Label, key))
+
+	return true
+}
+
+// SetData sets the text of the node, which is no longer a CDATA section.
+// The text segments of mixed content are replaced by a single one, before the child elements
+func (n *Node) SetData(data string) {
+	n.Data = data
+	n.CDATA = false
+	if n.Segments == nil {
+		return
+	}
+
+	segments := make([]Segment, 0, len(n.Segments)+1)
+	if data != "" {
+		segments = append(segments, Segment{Text: data})
+	}
+	for _, s := range n.Segments {
+		if !s.IsText() {
+			segments = append(segments, s)
+		}
+	}
+	n.Segments = segments
+}
+
+// Rename moves the children stored under oldKey to newKey, and returns whether oldKey was found.
+// They take the place of oldKey in ChildKeys, or come after the children already stored under newKey
+func (n *Node) Rename(oldKey string, newKey string) bool {
+	children, exists := n.Children[oldKey]
+	if !exists {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+
+	if _, exists := n.Children[newKey]; exists {
+		n.Children[newKey] = append(n.Children[newKey], children...)
+		n.dropKey(oldKey)
+	} else {
+		n.Children[newKey] = children
+		n.dropKey(newKey)
+		if n.listed[oldKey] {
+			for i, k := range n.keys {
+				if k == oldKey {
+					n.keys[i] = newKey
+				}
+			}
+			delete(n.listed, oldKey)
+			n.listed[newKey] = true
+		}
+	}
+	delete(n.Children, oldKey)
+	for _, c := range children {
+		c.setLabels(joinPath(n.Label, newKey))
+	}
+	for i, s := range n.Segments {
+		if s.Label == oldKey && !s.IsText() {
+			n.Segments[i].Label = newKey
+		}
+	}
+
+	return true
+}
+
+// Move removes the child c stored under key and adds it to the node to under toKey,
+// and returns whether it was done. A node cannot be moved within itself
+func (n *Node) Move(key string, c *Node, to *Node, toKey string) bool {
+	if !n.hasChild(key, c) || c.contains(to) {
+		return false
+	}
+
+	n.RemoveChild(key, c)
+	to.AddChild(toKey, c)
+	if to.Segments != nil {
+		to.Segments = append(to.Segments, Segment{Label: toKey, Node: c})
+	}
+	c.setLabels(joinPath(to.Label, toKey))
+
+	return true
+}
+
+// contains returns whether d is the node or one of its descendants
+func (n *Node) contains(d *Node) bool {
+	if n == d {
+		return true
+	}
+	for _, children := range n.Children {
+		for _, c := range children {
+			if c.contains(d) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,7 +1,10 @@
 package xml2json
 
 import (
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -43,3 +46,319 @@ func TestIsComplex(t *testing.T) {
 	n.Data = "foo"
 	assert.True(n.IsComplex(), "data does not impact IsComplex")
 }
+
+func TestChildKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	n := Node{}
+	assert.Empty(n.ChildKeys())
+
+	n.AddChild("b", &Node{})
+	n.AddChild("a", &Node{})
+	n.AddChild("b", &Node{})
+	n.AddChild("c", &Node{})
+	assert.Equal([]string{"b", "a", "c"}, n.ChildKeys())
+
+	delete(n.Children, "a")
+	n.Children["e"] = Nodes{{}}
+	n.Children["d"] = Nodes{{}}
+	assert.Equal([]string{"b", "c", "d", "e"}, n.ChildKeys())
+
+	n.AddChild("a", &Node{})
+	assert.Equal([]string{"b", "c", "a", "d", "e"}, n.ChildKeys())
+
+	assert.True(n.Rename("d", "f"))
+	n.AddChild("g", &Node{})
+	assert.True(n.Rename("c", "h"))
+	assert.Equal([]string{"b", "h", "a", "g", "e", "f"}, n.ChildKeys())
+}
+
+func BenchmarkDecodeDistinctKeys(b *testing.B) {
+	var doc strings.Builder
+	doc.WriteString("<r>")
+	for i := 0; i < 50000; i++ {
+		doc.WriteString("<k" + strconv.Itoa(i) + "/>")
+	}
+	doc.WriteString("</r>")
+
+	for i := 0; i < b.N; i++ {
+		root := &Node{}
+		err := NewDecoder(strings.NewReader(doc.String())).Decode(root)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	err := NewDecoder(strings.NewReader(`<osm>
+		<node id="1"><tag k="name" v="a.b"/><tag k="amenity" v="cafe"/></node>
+		<node id="2" visible="true"><tag k="name" v="c"/></node>
+		<node id="3"><tag k="highway" v="stop"/></node>
+		<way id="4"><nd ref="1"/><tag k="name" v="road"/></way>
+	</osm>`), WithAttrPrefix("-")).Decode(root)
+	assert.NoError(err)
+
+	data := func(expr string) []string {
+		nodes, err := root.Query(expr)
+		assert.NoError(err, expr)
+		var result []string
+		for _, n := range nodes {
+			result = append(result, n.Data)
+		}
+		return result
+	}
+
+	assert.Equal([]string{"3"}, data("osm.node[2].-id"))
+	assert.Equal([]string{"1", "2", "3"}, data("osm.node.-id"))
+	assert.Equal([]string{"a.b", "c", "road"}, data("osm.*.tag[-k=name].-v"))
+	assert.Equal([]string{"a.b", "c", "road"}, data(`osm.*.tag[-k="name"].-v`))
+	assert.Equal([]string{"2"}, data("osm.node[-visible].-id"))
+	assert.Equal([]string{"1"}, data("osm.node[-id=1].-id"))
+	assert.Equal([]string{"1"}, data("osm.*[-id][0].-id"))
+	assert.Equal([]string{"1"}, data("osm.node[tag][0].-id"))
+	assert.Equal([]string{"name", "name", "highway", "name"}, data("**.tag[-v][0].-k"))
+	assert.Equal([]string{"1", "2", "3", "4"}, data("**.-id"))
+	assert.Equal([]string{"1"}, data("osm.**.**.nd.-ref"))
+	assert.Equal([]string{"name"}, data("**.tag[-v='a.b'].-k"))
+	assert.Empty(data("osm.node[5]"))
+
+	// Grouped by key
+	grouped := &Node{}
+	assert.NoError(NewDecoder(strings.NewReader(`<r><a>1</a><b>2</b><a>3</a></r>`)).Decode(grouped))
+	nodes, err := grouped.Query("r.*")
+	assert.NoError(err)
+	assert.Len(nodes, 3)
+	assert.Equal([]string{"1", "3", "2"}, []string{nodes[0].Data, nodes[1].Data, nodes[2].Data})
+	assert.Empty(data("osm.missing.tag"))
+
+	nodes, err = root.Query("osm.node[1]")
+	assert.NoError(err)
+	assert.Len(nodes, 1)
+	assert.Equal("osm.node", nodes[0].Label)
+
+	for _, expr := range []string{"", "osm..node", "osm.node[", "osm.node[]", "osm.node[-1]", "osm.node[0]x", "osm.**[0]", "osm.node[=a]"} {
+		_, err := root.Query(expr)
+		assert.Error(err, expr)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	err := NewDecoder(strings.NewReader(`<a><b><c/></b><d/><e><f/></e></a>`)).Decode(root)
+	assert.NoError(err)
+
+	var visits []string
+	root.Walk(WalkFuncs{
+		OnEnter: func(key string, n *Node) WalkAction {
+			visits = append(visits, "+"+key)
+			if key == "b" {
+				return WalkSkip
+			}
+			return WalkContinue
+		},
+		OnLeave: func(key string, n *Node) WalkAction {
+			visits = append(visits, "-"+key)
+			return WalkContinue
+		},
+	})
+	assert.Equal([]string{"+", "+a", "+b", "-b", "+d", "-d", "+e", "+f", "-f", "-e", "-a", "-"}, visits)
+
+	visits = nil
+	root.Walk(WalkFuncs{
+		OnEnter: func(key string, n *Node) WalkAction {
+			visits = append(visits, key)
+			if key == "d" {
+				return WalkStop
+			}
+			return WalkContinue
+		},
+	})
+	assert.Equal([]string{"", "a", "b", "c", "d"}, visits)
+
+	// Removed siblings are not visited
+	visits = nil
+	root.Walk(WalkFuncs{
+		OnEnter: func(key string, n *Node) WalkAction {
+			visits = append(visits, key)
+			if key == "b" {
+				a := root.GetChild("a")
+				a.RemoveChild("d", a.GetChild("d"))
+			}
+			return WalkContinue
+		},
+	})
+	assert.Equal([]string{"", "a", "b", "c", "e", "f"}, visits)
+}
+
+func TestMutations(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	err := NewDecoder(strings.NewReader(`<doc><p>Hello <b>big</b> world <i>!</i></p><list><item>1</item><item>2</item></list></doc>`),
+		WithMixedContent(MixedContentParts)).Decode(root)
+	assert.NoError(err)
+
+	encode := func() string {
+		buf := new(strings.Builder)
+		err := NewEncoder(buf, WithMixedContent(MixedContentParts), WithCompact()).Encode(root)
+		assert.NoError(err)
+		return buf.String()
+	}
+
+	doc := root.GetChild("doc")
+	p := doc.GetChild("p")
+	list := doc.GetChild("list")
+	items := list.Children["item"]
+
+	assert.True(p.Rename("b", "strong"))
+	assert.Equal("doc.p.strong", p.GetChild("strong").Label)
+	assert.Equal([]string{"strong", "i"}, p.ChildKeys())
+	assert.False(p.Rename("b", "em"))
+
+	assert.True(p.ReplaceChild("i", p.GetChild("i"), &Node{Data: "?"}))
+	assert.Equal("doc.p.i", p.GetChild("i").Label)
+	assert.False(p.ReplaceChild("i", &Node{}, &Node{}))
+	assert.False(p.ReplaceChild("i", p.GetChild("i"), nil))
+	assert.Equal(`{"doc":{"p":{"content":["Hello",{"strong":"big"},"world",{"i":"?"}]},"list":{"item":["1","2"]}}}`+"\n", encode())
+
+	p.SetData("Hi")
+	assert.Equal(`{"doc":{"p":{"content":["Hi",{"strong":"big"},{"i":"?"}]},"list":{"item":["1","2"]}}}`+"\n", encode())
+
+	assert.True(list.Move("item", items[0], p, "item"))
+	assert.Equal("doc.p.item", items[0].Label)
+	assert.False(list.Move("item", items[0], p, "item"))
+	assert.False(doc.Move("p", p, p, "p"), "a node cannot be moved within itself")
+	assert.Equal(`{"doc":{"p":{"content":["Hi",{"strong":"big"},{"i":"?"},{"item":"1"}]},"list":{"item":"2"}}}`+"\n", encode())
+
+	assert.True(doc.Rename("list", "p"))
+	assert.Equal([]string{"p"}, doc.ChildKeys())
+	assert.Equal("doc.p.item", items[1].Label)
+
+	assert.True(p.RemoveChild("strong", p.GetChild("strong")))
+	assert.False(p.RemoveChild("strong", &Node{}))
+	assert.True(p.RemoveChild("i", p.GetChild("i")))
+	assert.Equal([]string{"item"}, p.ChildKeys())
+	assert.Equal(`{"doc":{"p":[{"content":["Hi",{"item":"1"}]},{"item":"2"}]}}`+"\n", encode())
+}
+
+type testTimestamp struct {
+	time.Time
+}
+
+func (t *testTimestamp) UnmarshalText(text []byte) error {
+	parsed, err := time.Parse("2006-01-02", string(text))
+	t.Time = parsed
+	return err
+}
+
+type testTag struct {
+	Key   string `json:"-k"`
+	Value string `json:"-v"`
+}
+
+type testBase struct {
+	ID      int64 `json:"-id"`
+	Visible *bool `json:"-visible"`
+}
+
+type testOSMNode struct {
+	testBase
+	Lat   float64       `json:"-lat"`
+	Tags  []testTag     `json:"tag"`
+	Extra any           `json:"extra"`
+	Date  testTimestamp `json:"date"`
+	Note  *string       `json:"note"`
+	Skip  string        `json:"-"`
+}
+
+type testOSM struct {
+	OSM struct {
+		Version string            `json:"-version"`
+		Nodes   []testOSMNode     `json:"node"`
+		Meta    map[string]string `json:"meta"`
+		Name    struct {
+			Lang string `json:"-lang"`
+			Text string `json:"#content"`
+		} `json:"name"`
+		Counts [2]uint8 `json:"count"`
+	} `json:"osm"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	assert := assert.New(t)
+
+	s := `<osm version="0.6">
+		<node id="1" lat="52.5" visible="true"><tag k="name" v="a"/><tag k="amenity" v="cafe"/><date>2024-02-29</date><note/></node>
+		<node id="2" lat="-1"><tag k="name" v="b"/><extra x="1"><y>true</y></extra><Skip>x</Skip></node>
+		<meta><source>survey</source><license>odbl</license></meta>
+		<name lang="en">Berlin</name>
+		<count>3</count><count>4</count><count>5</count>
+	</osm>`
+	plugins := []Plugin{WithAttrPrefix("-"), WithContentPrefix("#"), WithTypeConverter(Bool, Int, Float)}
+
+	root := &Node{}
+	err := NewDecoder(strings.NewReader(s), plugins...).Decode(root)
+	assert.NoError(err)
+
+	var v testOSM
+	err = root.Unmarshal(&v, plugins...)
+	assert.NoError(err)
+
+	osm := v.OSM
+	assert.Equal("0.6", osm.Version)
+	assert.Len(osm.Nodes, 2)
+	assert.Equal(int64(1), osm.Nodes[0].ID)
+	assert.Equal(52.5, osm.Nodes[0].Lat)
+	assert.True(*osm.Nodes[0].Visible)
+	assert.Equal([]testTag{{Key: "name", Value: "a"}, {Key: "amenity", Value: "cafe"}}, osm.Nodes[0].Tags)
+	assert.Equal("2024-02-29", osm.Nodes[0].Date.Format("2006-01-02"))
+	assert.NotNil(osm.Nodes[0].Note)
+	assert.Equal("", *osm.Nodes[0].Note)
+	assert.Nil(osm.Nodes[0].Extra)
+
+	assert.Nil(osm.Nodes[1].Visible)
+	assert.Equal(-1.0, osm.Nodes[1].Lat)
+	assert.Equal([]testTag{{Key: "name", Value: "b"}}, osm.Nodes[1].Tags, "single children fill slices")
+	assert.Equal(map[string]any{"-x": float64(1), "y": true}, osm.Nodes[1].Extra)
+	assert.Empty(osm.Nodes[1].Skip)
+
+	assert.Equal(map[string]string{"source": "survey", "license": "odbl"}, osm.Meta)
+	assert.Equal("en", osm.Name.Lang)
+	assert.Equal("Berlin", osm.Name.Text)
+	assert.Equal([2]uint8{3, 4}, osm.Counts)
+
+	// Key transforms and renames apply to the keys
+	var renamed struct {
+		Osm struct {
+			Ver   string `json:"ver"`
+			Nodes []struct {
+				ID int `json:"-id"`
+			} `json:"node"`
+		} `json:"osm"`
+	}
+	err = root.Unmarshal(&renamed, append(plugins, WithRenames(map[string]string{"osm.-version": "ver"}))...)
+	assert.NoError(err)
+	assert.Equal("0.6", renamed.Osm.Ver)
+	assert.Len(renamed.Osm.Nodes, 2)
+
+	var invalid struct {
+		OSM struct {
+			Nodes []struct {
+				Lat int `json:"-lat"`
+			} `json:"node"`
+		} `json:"osm"`
+	}
+	assert.Error(root.Unmarshal(&invalid, plugins...))
+	assert.Error(root.Unmarshal(v, plugins...))
+
+	var name struct {
+		Name int `json:"name"`
+	}
+	assert.Error(root.GetChild("osm").Unmarshal(&name, plugins...))
+}
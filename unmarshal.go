@@ -0,0 +1,443 @@
+package xml2json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// unmarshaler maps nodes onto Go values the way the JSON written by an Encoder with the same plugins would be
+type unmarshaler struct {
+	enc     *Encoder
+	plugins []Plugin
+}
+
+// structField is a field of a struct, or of its embedded structs, with its json name
+type structField struct {
+	name  string
+	index []int
+}
+
+// Unmarshal stores the node into the value pointed to by v, as json.Unmarshal would do
+// with the output of an Encoder built with the same plugins, without writing it:
+// struct fields are matched with the json keys using their json tags, and the text of the node
+// goes to the field named after the content key.
+// Leaf values are parsed according to the type of their target, empty values leaving it unchanged,
+// and targets implementing encoding.TextUnmarshaler get the raw text, even if they implement json.Unmarshaler.
+// Slice targets accept a single child as a one-element slice.
+// Targets of type any and json.Unmarshaler get the JSON encoding of their node
+func (n *Node) Unmarshal(v any, plugins ...Plugin) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.Errorf("unmarshal into non-pointer or nil %T", v)
+	}
+
+	u := &unmarshaler{
+		enc:     NewEncoder(io.Discard, plugins...),
+		plugins: plugins,
+	}
+	if u.enc.stripRoot && n.Label == "" {
+		_, n = documentElement(n)
+	}
+	return u.value(n, rv.Elem())
+}
+
+func (u *unmarshaler) value(n *Node, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if isEmpty(n) && v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return u.value(n, v.Elem())
+	}
+
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) && !n.IsComplex() {
+		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(n.Data))
+		if err != nil {
+			return errors.WithMessagef(err, "unmarshal %s", n.Label)
+		}
+		return nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(jsonUnmarshalerType) {
+		return u.viaJSON(n, v.Addr().Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return errors.Errorf("unmarshal %s into non-empty interface %s", n.Label, v.Type())
+		}
+		var x any
+		err := u.viaJSON(n, &x)
+		if err != nil {
+			return err
+		}
+		if x != nil {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Struct:
+		return u.object(n, v)
+	case reflect.Map:
+		return u.mapping(n, v)
+	case reflect.Slice, reflect.Array:
+		return u.list(Nodes{n}, v)
+	default:
+		return u.scalar(n, v)
+	}
+}
+
+// children stores the nodes of a key, a single one unless the target is a list
+func (u *unmarshaler) children(nodes Nodes, v reflect.Value) error {
+	t := v.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	isList := t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+	if !isList || reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		if len(nodes) > 1 && v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+			// Repeated elements are an array in JSON
+			values := make([]any, len(nodes))
+			for i, c := range nodes {
+				err := u.viaJSON(c, &values[i])
+				if err != nil {
+					return err
+				}
+			}
+			v.Set(reflect.ValueOf(values))
+			return nil
+		}
+		if len(nodes) > 1 {
+			return errors.Errorf("unmarshal %d elements %s into %s", len(nodes), nodes[0].Label, v.Type())
+		}
+		return u.value(nodes[0], v)
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return u.list(nodes, v)
+}
+
+func (u *unmarshaler) list(nodes Nodes, v reflect.Value) error {
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), len(nodes), len(nodes)))
+	}
+
+	for i, c := range nodes {
+		if i >= v.Len() {
+			break
+		}
+		err := u.value(c, v.Index(i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unmarshaler) object(n *Node, v reflect.Value) error {
+	fields := structFields(v.Type())
+
+	if key, content, ok := u.content(n); ok {
+		f, found := lookupField(fields, key)
+		if !found && !n.IsComplex() {
+			return errors.Errorf("unmarshal text of %s into %s without %q field", n.Label, v.Type(), key)
+		}
+		if found {
+			err := u.value(content, fieldByIndex(v, f.index))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range n.ChildKeys() {
+		children := n.Children[key]
+		f, found := lookupField(fields, u.enc.outputKey(key, children[0]))
+		if !found {
+			continue
+		}
+
+		err := u.children(children, fieldByIndex(v, f.index))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (u *unmarshaler) mapping(n *Node, v reflect.Value) error {
+	keyType := v.Type().Key()
+	if keyType.Kind() != reflect.String {
+		return errors.Errorf("unmarshal %s into map with %s keys", n.Label, keyType)
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	set := func(key string, nodes Nodes) error {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		err := u.children(nodes, elem)
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(key).Convert(keyType), elem)
+		return nil
+	}
+
+	if key, content, ok := u.content(n); ok {
+		err := set(key, Nodes{content})
+		if err != nil {
+			return err
+		}
+	}
+	for _, key := range n.ChildKeys() {
+		children := n.Children[key]
+		err := set(u.enc.outputKey(key, children[0]), children)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// content returns the key and a leaf holding the text of the node, if any
+func (u *unmarshaler) content(n *Node) (string, *Node, bool) {
+	if n.Data == "" && !u.enc.isCDATA(n) {
+		return "", nil, false
+	}
+
+	key := u.enc.contentKey()
+	if u.enc.isCDATA(n) {
+		key = u.enc.cdataKey
+	}
+	return key, &Node{Label: n.Label, Data: n.Data}, true
+}
+
+func (u *unmarshaler) scalar(n *Node, v reflect.Value) error {
+	if n.IsComplex() {
+		return errors.Errorf("unmarshal %s with children into %s", n.Label, v.Type())
+	}
+
+	s := n.Data
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return nil
+	}
+
+	s = strings.TrimSpace(s)
+	if s == "" || isNull(s) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if !isBool(s) {
+			return errors.Errorf("unmarshal %s: %q is not a bool", n.Label, s)
+		}
+		v.SetBool(s == "true")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("unmarshal %s: %q is not an %s", n.Label, s, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("unmarshal %s: %q is not an %s", n.Label, s, v.Type())
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("unmarshal %s: %q is not a %s", n.Label, s, v.Type())
+		}
+		v.SetFloat(f)
+	default:
+		return errors.Errorf("unmarshal %s into unsupported %s", n.Label, v.Type())
+	}
+
+	return nil
+}
+
+// viaJSON encodes the node and decodes the result into target
+func (u *unmarshaler) viaJSON(n *Node, target any) error {
+	buf := new(bytes.Buffer)
+	err := NewEncoder(buf, u.plugins...).Encode(n)
+	if err != nil {
+		return errors.WithMessagef(err, "encode %s", n.Label)
+	}
+
+	err = json.Unmarshal(buf.Bytes(), target)
+	if err != nil {
+		return errors.WithMessagef(err, "unmarshal %s", n.Label)
+	}
+	return nil
+}
+
+// isEmpty returns whether the node holds nothing, like a null value
+func isEmpty(n *Node) bool {
+	return !n.IsComplex() && strings.TrimSpace(n.Data) == "" && !n.CDATA
+}
+
+// structFields returns the fields of a struct type with their json names,
+// the fields of embedded structs coming after the others
+func structFields(t reflect.Type) []structField {
+	var (
+		fields   []structField
+		embedded []structField
+	)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if !f.IsExported() && f.Type.Kind() == reflect.Pointer {
+				// Unexported embedded pointers cannot be allocated
+				continue
+			}
+			for _, ef := range structFields(ft) {
+				embedded = append(embedded, structField{name: ef.name, index: append([]int{i}, ef.index...)})
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{name: name, index: []int{i}})
+	}
+	return append(fields, embedded...)
+}
+
+// lookupField returns the field named key, or else the first one whose name matches it ignoring case like encoding/json
+func lookupField(fields []structField, key string) (structField, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
+
+// fieldByIndex returns a field of a struct, allocating the embedded struct pointers on its way
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// structType is a Go type mapped onto the nodes found at path, see FromStructAt
+type structType struct {
+	path string
+	typ  reflect.Type
+}
+
+// setStructHints sets the hints of the struct types, once
+func (enc *Encoder) setStructHints() {
+	if len(enc.structTypes) == 0 || enc.structArrays != nil {
+		return
+	}
+
+	enc.structTypeHints = make(map[string]JSType)
+	enc.structArrays = make(map[string]bool)
+	for _, st := range enc.structTypes {
+		enc.addStructHints(enc.outputPath(st.path, ElementNode), st.typ, make(map[reflect.Type]bool))
+	}
+}
+
+// typeHint returns the type of the value of the node set by WithTypeHints or FromStruct
+func (enc *Encoder) typeHint(n *Node) (JSType, bool) {
+	if hint, ok := enc.typeHints.lookup(n.Label); ok {
+		return hint, true
+	}
+	if len(enc.structTypeHints) == 0 {
+		return 0, false
+	}
+	hint, ok := enc.structTypeHints[enc.outputPath(n.Label, n.Type)]
+	return hint, ok
+}
+
+// addStructHints sets the array and type hints of the values of type t found at the json path, see FromStruct
+func (enc *Encoder) addStructHints(path string, t reflect.Type, visiting map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		enc.structTypeHints[path] = String
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		enc.structTypeHints[path] = Bool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		enc.structTypeHints[path] = Int
+	case reflect.Float32, reflect.Float64:
+		enc.structTypeHints[path] = Float
+	case reflect.String:
+		enc.structTypeHints[path] = String
+	case reflect.Slice, reflect.Array:
+		enc.structArrays[path] = true
+		enc.addStructHints(path, t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			// Recursive types are only followed once
+			return
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		for _, f := range structFields(t) {
+			fieldPath := joinPath(path, f.name)
+			if f.name == enc.contentKey() {
+				fieldPath = path
+			}
+			enc.addStructHints(fieldPath, t.FieldByIndex(f.index).Type, visiting)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package xml2json
+
+// WalkAction tells Walk how to go on after visiting a node
+type WalkAction int
+
+const (
+	// WalkContinue visits the children of the node, then its next siblings
+	WalkContinue WalkAction = iota
+	// WalkSkip does not visit the children of the node when returned by Enter
+	WalkSkip
+	// WalkStop ends the walk
+	WalkStop
+)
+
+// A Visitor is called by Walk when entering a node, before its children, and when leaving it, after them
+type Visitor interface {
+	Enter(key string, n *Node) WalkAction
+	Leave(key string, n *Node) WalkAction
+}
+
+// WalkFuncs is a Visitor calling its functions, nil functions being skipped
+type WalkFuncs struct {
+	OnEnter func(key string, n *Node) WalkAction
+	OnLeave func(key string, n *Node) WalkAction
+}
+
+func (f WalkFuncs) Enter(key string, n *Node) WalkAction {
+	if f.OnEnter == nil {
+		return WalkContinue
+	}
+	return f.OnEnter(key, n)
+}
+
+func (f WalkFuncs) Leave(key string, n *Node) WalkAction {
+	if f.OnLeave == nil {
+		return WalkContinue
+	}
+	return f.OnLeave(key, n)
+}
+
+// Walk visits the node and its descendants depth first, children in the order of ChildKeys.
+// The node is visited with an empty key. Leave is called even if Enter skipped the children, but not after WalkStop.
+// Visitors may change the children of the node they visit, nodes removed before being reached are not visited
+func (n *Node) Walk(v Visitor) {
+	n.walk("", v)
+}
+
+func (n *Node) walk(key string, v Visitor) WalkAction {
+	action := v.Enter(key, n)
+	if action == WalkStop {
+		return WalkStop
+	}
+
+	if action != WalkSkip {
+		for _, k := range n.ChildKeys() {
+			// The children are copied as the visitor may change them
+			for _, c := range append(Nodes(nil), n.Children[k]...) {
+				if !n.hasChild(k, c) {
+					continue
+				}
+				if c.walk(k, v) == WalkStop {
+					return WalkStop
+				}
+			}
+		}
+	}
+
+	return v.Leave(key, n)
+}
@@ -0,0 +1,110 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"strings"
+	"unicode"
+)
+
+// WhitespaceMode tells how the whitespace of text is handled
+type WhitespaceMode int
+
+const (
+	// WhitespaceTrim removes leading and trailing whitespace and non graphic characters, see TrimNonGraphic (default)
+	WhitespaceTrim WhitespaceMode = iota
+	// WhitespacePreserve keeps text as is
+	WhitespacePreserve
+	// WhitespaceCollapse trims text and replaces inner runs of whitespace with a single space
+	WhitespaceCollapse
+	// WhitespaceNormalizeNewlines keeps text as is but line separators, which become "\n"
+	WhitespaceNormalizeNewlines
+)
+
+var newlines = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\u0085", "\n", "\u2028", "\n", "\u2029", "\n")
+
+// apply returns the text with its whitespace handled according to the mode
+func (m WhitespaceMode) apply(s string) string {
+	switch m {
+	case WhitespacePreserve:
+		return s
+	case WhitespaceCollapse:
+		return strings.Join(strings.Fields(TrimNonGraphic(s)), " ")
+	case WhitespaceNormalizeNewlines:
+		return newlines.Replace(s)
+	default:
+		return TrimNonGraphic(s)
+	}
+}
+
+// cdataMark stands for a CDATA section in applyAround, being graphic it stops the trimming
+const cdataMark = "|"
+
+// applyAround returns the text before and after a CDATA section with their whitespace handled
+// as if they were a single text with the section in between
+func (m WhitespaceMode) applyAround(before string, after string) (string, string) {
+	before = strings.TrimSuffix(m.apply(before+cdataMark), cdataMark)
+	after = strings.TrimPrefix(m.apply(cdataMark+after), cdataMark)
+	return before, after
+}
+
+// whitespaceMode returns the mode of a new element: a mode set for its path wins over xml:space,
+// which wins over the mode set for the whole document
+func (dec *Decoder) whitespaceMode(elem *element, attrs []xml.Attr) WhitespaceMode {
+	elem.preserveSpace = elem.parent.preserveSpace
+	if !dec.ignoreXMLSpace {
+		for _, a := range attrs {
+			if a.Name.Space == xmlURL && a.Name.Local == "space" {
+				elem.preserveSpace = a.Value == "preserve"
+			}
+		}
+	}
+
+	if mode, ok := dec.whitespacePaths.lookup(elem.path); ok {
+		return mode
+	}
+	if elem.preserveSpace {
+		return WhitespacePreserve
+	}
+	return dec.whitespace
+}
+
+// dropIndentation drops text made of whitespace only from elements holding child elements,
+// which is the indentation of the document rather than content
+func dropIndentation(n *Node) {
+	if n.CDATA || !hasElements(n) {
+		return
+	}
+
+	for _, s := range n.Segments {
+		if s.IsText() && !isBlank(s.Text) {
+			return
+		}
+	}
+	if !isBlank(n.Data) {
+		return
+	}
+
+	n.Data = ""
+	segments := n.Segments[:0]
+	for _, s := range n.Segments {
+		if !s.IsText() {
+			segments = append(segments, s)
+		}
+	}
+	n.Segments = segments
+}
+
+func hasElements(n *Node) bool {
+	for _, children := range n.Children {
+		if children[0].Type == ElementNode {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsSpace(r)
+	}) < 0
+}
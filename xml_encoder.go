@@ -0,0 +1,249 @@
+package xml2json
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// An XMLEncoder writes a tree of nodes as an XML document.
+// Attribute nodes become attributes, named after their key without the attribute prefix,
+// data becomes text and every node of a child list becomes a repeated element.
+type XMLEncoder struct {
+	writer          *bufio.Writer
+	attributePrefix string
+	procInstPrefix  string
+}
+
+// NewXMLEncoder returns a new encoder that writes to writer.
+func NewXMLEncoder(writer io.Writer, plugins ...Plugin) *XMLEncoder {
+	enc := NewEncoder(io.Discard, plugins...)
+	return &XMLEncoder{
+		writer:          bufio.NewWriter(writer),
+		attributePrefix: enc.attributePrefix,
+		procInstPrefix:  enc.procInstPrefix,
+	}
+}
+
+// Encode writes the children of root as XML elements
+func (enc *XMLEncoder) Encode(root *Node) error {
+	if root == nil {
+		return nil
+	}
+
+	for _, key := range root.ChildKeys() {
+		for _, c := range root.Children[key] {
+			if c.Type == AttributeNode {
+				continue
+			}
+
+			err := enc.encodeNode(key, c)
+			if err != nil {
+				return errors.WithMessagef(err, "encode %s", key)
+			}
+		}
+	}
+
+	// Terminate the document with a newline like Encoder does
+	enc.writer.WriteString("\n")
+
+	return enc.writer.Flush()
+}
+
+// encodeNode writes an element, a comment or a processing instruction
+func (enc *XMLEncoder) encodeNode(key string, n *Node) error {
+	switch n.Type {
+	case CommentNode:
+		if strings.Contains(n.Data, "--") || strings.HasSuffix(n.Data, "-") {
+			return errors.Errorf("invalid comment %q", n.Data)
+		}
+		enc.writer.WriteString("<!--")
+		enc.writer.WriteString(n.Data)
+		enc.writer.WriteString("-->")
+		return nil
+	case ProcInstNode:
+		target := strings.TrimPrefix(key, enc.procInstPrefix)
+		if target == "" {
+			return errors.Errorf("empty processing instruction target for %s", key)
+		}
+		if !isName(target, false) || strings.EqualFold(target, xmlPrefix) {
+			return errors.Errorf("invalid processing instruction target %q", target)
+		}
+		if strings.Contains(n.Data, "?>") {
+			return errors.Errorf("invalid processing instruction %q", n.Data)
+		}
+		enc.writer.WriteString("<?")
+		enc.writer.WriteString(target)
+		if n.Data != "" {
+			enc.writer.WriteString(" ")
+			enc.writer.WriteString(n.Data)
+		}
+		enc.writer.WriteString("?>")
+		return nil
+	default:
+		return enc.encodeElement(key, n)
+	}
+}
+
+func (enc *XMLEncoder) encodeElement(name string, n *Node) error {
+	if name == "" {
+		return errors.New("empty element name")
+	}
+
+	var declaration string
+	name, declaration = fromClark(name)
+	if !isName(name, true) {
+		return errors.Errorf("invalid element name %q", name)
+	}
+
+	enc.writer.WriteString("<")
+	enc.writer.WriteString(name)
+	if declaration != "" {
+		enc.writeAttribute(xmlnsPrefix, declaration)
+	}
+
+	// A key may hold both an attribute and elements, see CollisionMerge
+	var elements []string
+	for _, key := range n.ChildKeys() {
+		var attrs Nodes
+		for _, c := range n.Children[key] {
+			if c.Type == AttributeNode {
+				attrs = append(attrs, c)
+			}
+		}
+		if len(attrs) < len(n.Children[key]) {
+			elements = append(elements, key)
+		}
+		if len(attrs) == 0 {
+			continue
+		}
+		if len(attrs) > 1 {
+			return errors.Errorf("attribute %s has %d values", key, len(attrs))
+		}
+
+		attr := fromClarkAttribute(strings.TrimPrefix(key, enc.attributePrefix))
+		if attr == "" {
+			return errors.Errorf("empty attribute name for %s", key)
+		}
+		if !isName(attr, true) {
+			return errors.Errorf("invalid attribute name %q", attr)
+		}
+		enc.writeAttribute(attr, attrs[0].Data)
+	}
+
+	if len(elements) == 0 && n.Data == "" && !n.CDATA {
+		enc.writer.WriteString("/>")
+		return nil
+	}
+	enc.writer.WriteString(">")
+
+	if n.IsMixed() {
+		for _, s := range n.Segments {
+			var err error
+			if s.IsText() {
+				err = enc.writeText(s.Text, s.CDATA)
+			} else {
+				err = enc.encodeNode(s.Label, s.Node)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		elements = keysOutsideSegments(elements, n.Segments)
+	} else {
+		err := enc.writeText(n.Data, n.CDATA)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range elements {
+		for _, c := range n.Children[key] {
+			if c.Type == AttributeNode {
+				continue
+			}
+			err := enc.encodeNode(key, c)
+			if err != nil {
+				return errors.WithMessagef(err, "encode %s", key)
+			}
+		}
+	}
+
+	enc.writer.WriteString("</")
+	enc.writer.WriteString(name)
+	enc.writer.WriteString(">")
+
+	return nil
+}
+
+func (enc *XMLEncoder) writeText(text string, cdata bool) error {
+	if !cdata {
+		return xml.EscapeText(enc.writer, []byte(text))
+	}
+
+	enc.writer.WriteString(cdataStart)
+	enc.writer.WriteString(escapeCDATA(text))
+	enc.writer.WriteString(cdataEnd)
+	return nil
+}
+
+func (enc *XMLEncoder) writeAttribute(name string, value string) {
+	enc.writer.WriteString(" ")
+	enc.writer.WriteString(name)
+	enc.writer.WriteString(`="`)
+	// EscapeText only fails on write errors, which are reported by Flush
+	_ = xml.EscapeText(enc.writer, []byte(value))
+	enc.writer.WriteString(`"`)
+}
+
+// fromClark splits an element name in Clark notation, {uri}local,
+// into its local name and the namespace to declare
+func fromClark(name string) (string, string) {
+	if !strings.HasPrefix(name, "{") {
+		return name, ""
+	}
+	end := strings.Index(name, "}")
+	if end < 0 {
+		return name, ""
+	}
+	return name[end+1:], name[1:end]
+}
+
+// fromClarkAttribute drops the namespace of an attribute name in Clark notation,
+// the xml namespace being kept as its reserved prefix
+func fromClarkAttribute(name string) string {
+	local, uri := fromClark(name)
+	if uri == xmlURL {
+		return qualify(xmlPrefix, local)
+	}
+	return local
+}
+
+// isName returns whether name is an XML name without colon, or with a single colon
+// between a prefix and a local name if qualified is set
+func isName(name string, qualified bool) bool {
+	if qualified {
+		if prefix, local, found := strings.Cut(name, ":"); found {
+			return isName(prefix, false) && isName(local, false)
+		}
+	}
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i == 0:
+			return false
+		case r == '-' || r == '.' || r == '\u00B7' || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc):
+		default:
+			return false
+		}
+	}
+	return true
+}